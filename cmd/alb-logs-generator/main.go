@@ -4,14 +4,25 @@ import (
 	"flag"
 	"fmt"
 	"math/rand"
+	"os"
 	"reflect"
 	"time"
 
 	"github.com/go-faker/faker/v4"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/albgen"
 )
 
 var startTime = time.Now().UTC().Add(-5 * time.Minute)
-var flagCount int
+var (
+	flagCount    int
+	flagScenario string
+	flagSeed     uint64
+	flagOut      string
+	flagStart    string
+	flagRules    string
+	flagExpected string
+)
 
 func init() {
 	_ = faker.AddProvider("custom_alb_time", func(v reflect.Value) (any, error) {
@@ -38,86 +49,31 @@ func init() {
 	})
 
 	flag.IntVar(&flagCount, "count", 300, "number of log lines to generate")
+	flag.StringVar(&flagScenario, "scenario", "", "scenario JSON file; writes gzip objects under -out instead of printing lines")
+	flag.Uint64Var(&flagSeed, "seed", 1, "random seed for -scenario")
+	flag.StringVar(&flagOut, "out", ".", "directory to write scenario objects to, laid out like the S3 bucket")
+	flag.StringVar(&flagStart, "start", "", "RFC3339 time of the first request, overriding the scenario start")
+	flag.StringVar(&flagRules, "rules", "", "rule JSON file used to compute -expected")
+	flag.StringVar(&flagExpected, "expected", "", "write the ground-truth path metrics for -rules to this JSON file")
 	flag.Parse()
 }
 
-type FakeALBLogFields struct {
-	Type                   string  `faker:"oneof: https"`
-	Time                   string  `faker:"custom_alb_time"`
-	ELB                    string  `faker:"oneof: app/prod-alb/50dc6c495c0c9188"`
-	ClientIP               string  `faker:"ipv4"`
-	ClientPort             int     `faker:"boundary_start=1024, boundary_end=65535"`
-	TargetIP               string  `faker:"oneof: 192.0.2.10, 192.0.2.11, 192.0.2.12"`
-	TargetPort             string  `faker:"oneof: 8080"`
-	RequestProcessingTime  float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
-	TargetProcessingTime   float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
-	ResponseProcessingTime float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
-	ELBStatusCode          int     `faker:"oneof: 200"`
-	TargetStatusCode       int     `faker:"oneof: 200"`
-	ReceivedBytes          int     `faker:"oneof: 0, 100, 500, 1000, 2000, 5000"`
-	SentBytes              int     `faker:"oneof: 0, 500, 1000, 5000, 10000, 50000"`
-	Request                string  `faker:"custom_alb_request"`
-	UserAgent              string  `faker:"user_agent"`
-	SSLCipher              string  `faker:"oneof: ECDHE-RSA-AES128-GCM-SHA256"`
-	SSLProtocol            string  `faker:"oneof: TLSv1.2, TLSv1.3"`
-	TargetGroupARN         string  `faker:"custom_alb_target_group_arn"`
-	TraceID                string  `faker:"oneof: Root=1-58337281-1d84f3d73c47ec4e58577259"`
-	DomainName             string  `faker:"oneof: www.example.com, admin.example.com"`
-	ChosenCertARN          string  `faker:"custom_alb_chosen_cert_arn"`
-	MatchedRulePriority    string  `faker:"oneof: 0, 1, 10, 100, 1000"`
-	RequestCreationTime    string  `faker:"custom_alb_time"`
-	ActionsExecuted        string  `faker:"oneof: -"`
-	RedirectURL            string  `faker:"oneof: -"`
-	ErrorReason            string  `faker:"oneof: -"`
-	PortList               int     `faker:"oneof: 8080"`
-	TargetStatusCodeList   int     `faker:"oneof: 200, 201, 204, 400, 500"`
-	Classification         string  `faker:"oneof: -"`
-	ClassificationReason   string  `faker:"oneof: -"`
-	ConnTraceID            string  `faker:"oneof: TID_1234abcd5678ef90"`
-}
+func main() {
+	if flagScenario != "" {
+		if err := runScenario(); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-func (f FakeALBLogFields) String() string {
-	return fmt.Sprintf(
-		"%s %s %s %s:%d %s:%s %.3f %.3f %.3f %d %d %d %d \"%s\" \"%s\" %s %s %s \"%s\" \"%s\" \"%s\" %s %s \"%s\" \"%s\" \"%s\" \"%s:%d\" \"%d\" \"%s\" \"%s\" %s",
-		f.Type,
-		f.Time,
-		f.ELB,
-		f.ClientIP,
-		f.ClientPort,
-		f.TargetIP,
-		f.TargetPort,
-		f.RequestProcessingTime,
-		f.TargetProcessingTime,
-		f.ResponseProcessingTime,
-		f.ELBStatusCode,
-		f.TargetStatusCode,
-		f.ReceivedBytes,
-		f.SentBytes,
-		f.Request,
-		f.UserAgent,
-		f.SSLCipher,
-		f.SSLProtocol,
-		f.TargetGroupARN,
-		f.TraceID,
-		f.DomainName,
-		f.ChosenCertARN,
-		f.MatchedRulePriority,
-		f.RequestCreationTime,
-		f.ActionsExecuted,
-		f.RedirectURL,
-		f.ErrorReason,
-		f.TargetIP,
-		f.PortList,
-		f.TargetStatusCodeList,
-		f.Classification,
-		f.ClassificationReason,
-		f.ConnTraceID,
-	)
-}
+	if flagExpected != "" {
+		fmt.Fprintln(os.Stderr, "error: -expected requires -scenario")
+		os.Exit(1)
+	}
 
-func main() {
 	for i := 0; i < flagCount; i++ {
-		var logEntry FakeALBLogFields
+		var logEntry albgen.Fields
 		err := faker.FakeData(&logEntry)
 		if err != nil {
 			fmt.Println("Error generating fake data:", err)
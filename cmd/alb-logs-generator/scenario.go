@@ -0,0 +1,159 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/albgen"
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+// objectFile is a gzip log object being written.
+type objectFile struct {
+	key  string
+	file *os.File
+	gz   *gzip.Writer
+}
+
+func (o *objectFile) Close() error {
+	if err := o.gz.Close(); err != nil {
+		o.file.Close()
+		return fmt.Errorf("close %s: %w", o.key, err)
+	}
+	return o.file.Close()
+}
+
+// runScenario generates the scenario and writes one gzip object per load balancer and delivery interval.
+func runScenario() error {
+	scenario, err := albgen.LoadScenario(flagScenario)
+	if err != nil {
+		return err
+	}
+
+	if flagStart != "" {
+		start, err := time.Parse(time.RFC3339, flagStart)
+		if err != nil {
+			return fmt.Errorf("parse -start: %w", err)
+		}
+		scenario.Start = start
+	}
+	if scenario.Start.IsZero() {
+		scenario.Start = time.Now().UTC().Add(-time.Duration(scenario.Duration)).Truncate(time.Minute)
+	}
+
+	var expected *expectedWriter
+	if flagExpected != "" {
+		expected, err = newExpectedWriter(flagRules)
+		if err != nil {
+			return err
+		}
+	}
+
+	// ALB writes each load balancer's objects in turn, so at most one object per load balancer is open.
+	open := make(map[string]*objectFile)
+	var objects, lines int
+
+	err = scenario.Generate(flagSeed, func(req albgen.Request) error {
+		obj := open[req.Fields.ELB]
+		if obj == nil || obj.key != req.Key {
+			if obj != nil {
+				if err := obj.Close(); err != nil {
+					return err
+				}
+			}
+
+			var err error
+			obj, err = createObject(req.Key)
+			if err != nil {
+				return err
+			}
+			open[req.Fields.ELB] = obj
+			objects++
+		}
+
+		if expected != nil {
+			if err := expected.record(req); err != nil {
+				return err
+			}
+		}
+
+		lines++
+		_, err := fmt.Fprintln(obj.gz, req.Fields.String())
+		return err
+	})
+
+	for _, obj := range open {
+		if closeErr := obj.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "wrote %d lines to %d objects under %s\n", lines, objects, flagOut)
+
+	if expected != nil {
+		if err := expected.metrics.WriteFile(flagExpected); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote expected metrics for %d matched lines to %s\n", expected.matched, flagExpected)
+	}
+	return nil
+}
+
+// expectedWriter accumulates the ground-truth path metrics for requests matched by a rule set.
+type expectedWriter struct {
+	rules   *metrics.PathRules
+	metrics albgen.ExpectedMetrics
+	matched int
+}
+
+func newExpectedWriter(rulesPath string) (*expectedWriter, error) {
+	if rulesPath == "" {
+		return nil, fmt.Errorf("-expected requires -rules")
+	}
+
+	raw, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+
+	rules, err := metrics.NewPathRules(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %w", err)
+	}
+
+	return &expectedWriter{rules: rules}, nil
+}
+
+func (w *expectedWriter) record(req albgen.Request) error {
+	match, err := w.rules.MatchRequest(req.Method, "https://"+req.Host+req.Path)
+	if err != nil {
+		return err
+	}
+	if match.Rule < 0 {
+		return nil
+	}
+
+	w.matched++
+	w.metrics.Record(req, match.Name)
+	return nil
+}
+
+func createObject(key string) (*objectFile, error) {
+	path := filepath.Join(flagOut, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create directory for %s: %w", key, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", key, err)
+	}
+
+	return &objectFile{key: key, file: file, gz: gzip.NewWriter(file)}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/cwgen"
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	rulesPath := fs.String("rules", "", "path to the rule JSON file (defaults to $INCLUDE_PATH_RULES)")
+	metricsPath := fs.String("metrics", "", "path to the default metric selection JSON file (defaults to $PATH_METRICS)")
+	slosPath := fs.String("slos", "", "path to the SLO JSON file (defaults to $SLOS; optional)")
+	format := fs.String("format", "api", "output format: api, cloudformation or terraform")
+	namespace := fs.String("namespace", metrics.Namespace, "namespace of the metrics")
+	name := fs.String("name", "alb-path-metrics", "dashboard name")
+	region := fs.String("region", os.Getenv("AWS_REGION"), "region of the metrics (defaults to $AWS_REGION)")
+	prefix := fs.String("prefix", "", "prefix for alarm names")
+	var actions stringList
+	fs.Var(&actions, "alarm-action", "ARN notified by the SLO alarms; can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "api" && *format != "cloudformation" && *format != "terraform" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *region == "" {
+		return fmt.Errorf("no region given: pass -region or set AWS_REGION")
+	}
+
+	raw, err := loadRules(*rulesPath)
+	if err != nil {
+		return err
+	}
+	rules, err := metrics.NewPathRules(raw)
+	if err != nil {
+		return err
+	}
+
+	rawSpecs := os.Getenv("PATH_METRICS")
+	if *metricsPath != "" {
+		b, err := os.ReadFile(*metricsPath)
+		if err != nil {
+			return fmt.Errorf("read metrics: %w", err)
+		}
+		rawSpecs = string(b)
+	}
+	specs, err := metrics.ParseMetricSpecs(rawSpecs)
+	if err != nil {
+		return err
+	}
+
+	var slos []metrics.SLO
+	if *slosPath != "" || os.Getenv("SLOS") != "" {
+		if slos, err = loadSLOs(*slosPath); err != nil {
+			return err
+		}
+	}
+
+	resources, err := cwgen.Generate(*name, rules.PublishedPaths(specs), slos, cwgen.Options{
+		Namespace:    *namespace,
+		Region:       *region,
+		AlarmPrefix:  *prefix,
+		AlarmActions: actions,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "cloudformation":
+		return writeJSON(resources.CloudFormation())
+	case "terraform":
+		return writeJSON(resources.Terraform())
+	default:
+		return writeJSON(resources)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/infra"
+)
+
+func runInfra(args []string) error {
+	fs := flag.NewFlagSet("infra", flag.ContinueOnError)
+	format := fs.String("format", "cloudformation", "output format: cloudformation or terraform")
+	pkg := fs.String("package", infra.DefaultPackage, "deployment package of the CloudFormation template, relative to the template")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var template any
+	switch *format {
+	case "cloudformation":
+		template = infra.CloudFormation(*pkg)
+	case "terraform":
+		template = infra.Terraform()
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	b, err := infra.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("encode template: %w", err)
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+const usage = `Usage: alb-path-metrics-cli <command> [flags]
+
+Commands:
+  rules lint     Report likely mistakes in the path rule configuration
+  rules test     Show which rule matches sample requests or ALB log lines
+  report         Report how much traffic in ALB log files the rules cover
+  slo alarms     Generate burn-rate alarms for SLOs as PutMetricAlarm and PutCompositeAlarm JSON
+  slo dashboard  Generate an SLO dashboard as PutDashboard JSON
+  generate       Generate the path dashboard and SLO alarms as API, CloudFormation or Terraform JSON
+  infra          Render the CloudFormation template or Terraform module that deploys the function
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing command")
+	}
+
+	switch args[0] {
+	case "rules":
+		return runRules(args[1:])
+	case "report":
+		return runReport(args[1:])
+	case "slo":
+		return runSLO(args[1:])
+	case "generate":
+		return runGenerate(args[1:])
+	case "infra":
+		return runInfra(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// openLogFile opens an ALB access log file, decompressing gzip, zstd or bzip2 content.
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open log: %w", err)
+	}
+
+	r, err := metrics.NewLogReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open log %s: %w", path, err)
+	}
+
+	return &logFile{ReadCloser: r, file: f}, nil
+}
+
+type logFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (l *logFile) Close() error {
+	l.ReadCloser.Close()
+	return l.file.Close()
+}
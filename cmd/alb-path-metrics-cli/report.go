@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	rulesPath := fs.String("rules", "", "path to the rule JSON file (defaults to $INCLUDE_PATH_RULES)")
+	format := fs.String("format", "table", "output format: table or json")
+	topN := fs.Int("top", 20, "number of unmatched path templates to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("at least one ALB log file is required")
+	}
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	raw, err := loadRules(*rulesPath)
+	if err != nil {
+		return err
+	}
+
+	rules, err := metrics.NewPathRules(raw)
+	if err != nil {
+		return err
+	}
+
+	processor := metrics.NewProcwessor(nil, nil, rules, metrics.ProcessorOptions{DryRun: true, Coverage: true})
+	for _, path := range fs.Args() {
+		if err := processLogFile(processor, path); err != nil {
+			return err
+		}
+	}
+
+	report, err := processor.CoverageReport(*topN)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		return writeJSON(report)
+	}
+
+	return writeReportTable(report)
+}
+
+func processLogFile(processor *metrics.Processor, path string) error {
+	r, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := processor.ProcessLines(r); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func writeReportTable(report metrics.CoverageReport) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "HOST\tMATCHED\tUNMATCHED\tCOVERAGE")
+	for _, h := range report.Hosts {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", h.Host, h.Matched, h.Unmatched, h.Coverage*100)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "RULE\tNAME\tHOST\tMETHOD\tMATCHED")
+	for _, r := range report.Rules {
+		method := r.Method
+		if method == "" {
+			method = "*"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", r.Rule, r.Name, r.Host, method, r.Matched)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "UNMATCHED\tMETHOD\tHOST\tPATH")
+	for _, u := range report.TopUnmatched {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", u.Count, u.Method, u.Host, u.Path)
+	}
+
+	return w.Flush()
+}
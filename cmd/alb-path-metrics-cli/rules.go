@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func runRules(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing rules subcommand: lint or test")
+	}
+
+	switch args[0] {
+	case "lint":
+		return runRulesLint(args[1:])
+	case "test":
+		return runRulesTest(args[1:])
+	default:
+		return fmt.Errorf("unknown rules subcommand %q", args[0])
+	}
+}
+
+func runRulesLint(args []string) error {
+	fs := flag.NewFlagSet("rules lint", flag.ContinueOnError)
+	rulesPath := fs.String("rules", "", "path to the rule JSON file (defaults to $INCLUDE_PATH_RULES)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	raw, err := loadRules(*rulesPath)
+	if err != nil {
+		return err
+	}
+
+	issues, err := metrics.LintPathRules(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues found", len(issues))
+	}
+
+	fmt.Println("No issues found")
+	return nil
+}
+
+func runRulesTest(args []string) error {
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	rulesPath := fs.String("rules", "", "path to the rule JSON file (defaults to $INCLUDE_PATH_RULES)")
+	samplesPath := fs.String("samples", "", `path to a sample table with "METHOD URL [EXPECTED_NAME]" per line; use - as EXPECTED_NAME for no match`)
+	logPath := fs.String("log", "", "path to an ALB access log file (plain, gzip, zstd or bzip2)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if (*samplesPath == "") == (*logPath == "") {
+		return fmt.Errorf("exactly one of -samples or -log is required")
+	}
+
+	raw, err := loadRules(*rulesPath)
+	if err != nil {
+		return err
+	}
+
+	rules, err := metrics.NewPathRules(raw)
+	if err != nil {
+		return err
+	}
+
+	if *samplesPath != "" {
+		return testSamples(rules, *samplesPath)
+	}
+
+	return testLogFile(rules, *logPath)
+}
+
+func testSamples(rules *metrics.PathRules, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open samples: %w", err)
+	}
+	defer f.Close()
+
+	var failures int
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return fmt.Errorf("samples line %d: expected METHOD URL [EXPECTED_NAME]", lineNum)
+		}
+
+		match, err := rules.MatchRequest(fields[0], fields[1])
+		if err != nil {
+			return fmt.Errorf("samples line %d: %w", lineNum, err)
+		}
+
+		result := fmt.Sprintf("%s %s -> %s", fields[0], fields[1], describeMatch(match))
+		if len(fields) == 3 {
+			want := fields[2]
+			got := match.Name
+			if got == "" {
+				got = "-"
+			}
+
+			if got == want {
+				result += " ok"
+			} else {
+				result += fmt.Sprintf(" FAIL (want %s)", want)
+				failures++
+			}
+		}
+
+		fmt.Println(result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read samples: %w", err)
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d samples did not match the expected rule", failures)
+	}
+
+	return nil
+}
+
+func testLogFile(rules *metrics.PathRules, path string) error {
+	r, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var matched, unmatched, invalid int
+	// The line reader skips oversized lines like the Lambda function does, instead of failing the file.
+	lines := metrics.NewLineReader(r, 0)
+	for lineNum := 1; ; lineNum++ {
+		line, oversized, err := lines.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+
+		if oversized > 0 {
+			fmt.Printf("line %d: line of %d bytes exceeds the %d byte limit\n", lineNum, oversized, lines.MaxBytes())
+			invalid++
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		match, err := rules.MatchLogLine(string(line))
+		if err != nil {
+			fmt.Printf("line %d: %v\n", lineNum, err)
+			invalid++
+			continue
+		}
+
+		if match.Rule < 0 {
+			unmatched++
+		} else {
+			matched++
+		}
+
+		fmt.Printf("line %d: %s %s %s -> %s\n", lineNum, match.Method, match.Host, match.Path, describeMatch(match))
+	}
+
+	fmt.Printf("matched=%d unmatched=%d invalid=%d\n", matched, unmatched, invalid)
+	return nil
+}
+
+func describeMatch(match metrics.PathRuleMatch) string {
+	if match.Rule < 0 {
+		return "no match"
+	}
+	return fmt.Sprintf("rule %d (%s)", match.Rule, match.Name)
+}
+
+func loadRules(path string) (string, error) {
+	if path == "" {
+		raw := os.Getenv("INCLUDE_PATH_RULES")
+		if raw == "" {
+			return "", fmt.Errorf("no rules given: pass -rules or set INCLUDE_PATH_RULES")
+		}
+		return raw, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read rules: %w", err)
+	}
+	return string(b), nil
+}
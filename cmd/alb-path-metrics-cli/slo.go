@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/cwgen"
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func runSLO(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing slo subcommand: alarms or dashboard")
+	}
+
+	switch args[0] {
+	case "alarms":
+		return runSLOAlarms(args[1:])
+	case "dashboard":
+		return runSLODashboard(args[1:])
+	default:
+		return fmt.Errorf("unknown slo subcommand %q", args[0])
+	}
+}
+
+func runSLOAlarms(args []string) error {
+	fs := flag.NewFlagSet("slo alarms", flag.ContinueOnError)
+	slosPath := fs.String("slos", "", "path to the SLO JSON file (defaults to $SLOS)")
+	namespace := fs.String("namespace", metrics.Namespace, "namespace of the SLO metrics")
+	prefix := fs.String("prefix", "", "prefix for alarm names")
+	var actions stringList
+	fs.Var(&actions, "alarm-action", "ARN notified by the composite alarms; can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	slos, err := loadSLOs(*slosPath)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(cwgen.SLOAlarms(slos, cwgen.Options{Namespace: *namespace, AlarmPrefix: *prefix, AlarmActions: actions}))
+}
+
+func runSLODashboard(args []string) error {
+	fs := flag.NewFlagSet("slo dashboard", flag.ContinueOnError)
+	slosPath := fs.String("slos", "", "path to the SLO JSON file (defaults to $SLOS)")
+	namespace := fs.String("namespace", metrics.Namespace, "namespace of the SLO metrics")
+	name := fs.String("name", "alb-path-slos", "dashboard name")
+	region := fs.String("region", os.Getenv("AWS_REGION"), "region of the metrics (defaults to $AWS_REGION)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *region == "" {
+		return fmt.Errorf("no region given: pass -region or set AWS_REGION")
+	}
+
+	slos, err := loadSLOs(*slosPath)
+	if err != nil {
+		return err
+	}
+
+	dashboard, err := cwgen.SLODashboard(*name, slos, cwgen.Options{Namespace: *namespace, Region: *region})
+	if err != nil {
+		return err
+	}
+	return writeJSON(dashboard)
+}
+
+func loadSLOs(path string) ([]metrics.SLO, error) {
+	raw := os.Getenv("SLOS")
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read SLOs: %w", err)
+		}
+		raw = string(b)
+	}
+
+	slos, err := metrics.ParseSLOs(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(slos) == 0 {
+		return nil, fmt.Errorf("no SLOs given: pass -slos or set SLOS")
+	}
+	return slos, nil
+}
+
+// writeJSON writes v to stdout as indented JSON.
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -14,28 +17,165 @@ import (
 	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
 )
 
+const coverageReportTopN = 20
+
 func handler(ctx context.Context, s3Event events.S3Event) error {
 	rules, err := metrics.NewPathRules(os.Getenv("INCLUDE_PATH_RULES"))
 	if err != nil {
 		return fmt.Errorf("parse path rules: %w", err)
 	}
 
+	metricSpecs, err := metrics.ParseMetricSpecs(os.Getenv("PATH_METRICS"))
+	if err != nil {
+		return fmt.Errorf("parse PATH_METRICS: %w", err)
+	}
+
+	clientTypes, err := metrics.NewClientClassifier(os.Getenv("CLIENT_TYPES"))
+	if err != nil {
+		return fmt.Errorf("parse CLIENT_TYPES: %w", err)
+	}
+
+	clientNetworks, err := metrics.NewClientNetworks(os.Getenv("CLIENT_NETWORKS"))
+	if err != nil {
+		return fmt.Errorf("parse CLIENT_NETWORKS: %w", err)
+	}
+	if clientNetworks != nil {
+		defer clientNetworks.Close()
+	}
+
+	slos, err := metrics.ParseSLOs(os.Getenv("SLOS"))
+	if err != nil {
+		return fmt.Errorf("parse SLOS: %w", err)
+	}
+
+	parseErrorThreshold, err := floatEnv("PARSE_ERROR_THRESHOLD")
+	if err != nil {
+		return err
+	}
+
+	parseErrorSamples, err := intEnv("PARSE_ERROR_SAMPLES")
+	if err != nil {
+		return err
+	}
+
+	maxLineBytes, err := intEnv("MAX_LINE_BYTES")
+	if err != nil {
+		return err
+	}
+
+	maxTargets, err := intEnv("MAX_TARGETS")
+	if err != nil {
+		return err
+	}
+
+	exemplars, err := intEnv("EXEMPLARS")
+	if err != nil {
+		return err
+	}
+
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return fmt.Errorf("load AWS config: %w", err)
 	}
 
+	// Path-style addressing lets AWS_ENDPOINT_URL point at a local stand-in such as cmd/local-aws.
+	usePathStyle := os.Getenv("S3_USE_PATH_STYLE") == "true"
+
 	processor := metrics.NewProcwessor(
-		s3.NewFromConfig(cfg),
+		s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = usePathStyle }),
 		cloudwatch.NewFromConfig(cfg),
 		rules,
-		os.Getenv("DRY_RUN") == "true",
-		os.Getenv("DEBUG") == "true",
+		metrics.ProcessorOptions{
+			DryRun:                os.Getenv("DRY_RUN") == "true",
+			Logger:                slog.Default(),
+			Coverage:              os.Getenv("COVERAGE_REPORT") == "true",
+			PublishUnmatchedCount: os.Getenv("PUBLISH_UNMATCHED_COUNT") == "true",
+			ParseErrorThreshold:   parseErrorThreshold,
+			ParseErrorSamples:     parseErrorSamples,
+			PipelineNamespace:     os.Getenv("PIPELINE_METRICS_NAMESPACE"),
+			KeyPrefix:             os.Getenv("LOG_KEY_PREFIX"),
+			MaxLineBytes:          maxLineBytes,
+			Metrics:               metricSpecs,
+			UnavailableAsFailed:   os.Getenv("UNAVAILABLE_AS_FAILED") == "true",
+			MaxTargets:            maxTargets,
+			ClientTypes:           clientTypes,
+			ClientNetworks:        clientNetworks,
+			Exemplars:             exemplars,
+			SLOs:                  slos,
+			TLSMetrics:            os.Getenv("TLS_METRICS") == "true",
+			TLSLegacyOnly:         os.Getenv("TLS_LEGACY_ONLY") == "true",
+		},
 	)
 
-	return processor.HandleEvent(ctx, s3Event)
+	if err := processor.HandleEvent(ctx, s3Event); err != nil {
+		return err
+	}
+
+	if os.Getenv("COVERAGE_REPORT") == "true" {
+		report, err := processor.CoverageReport(coverageReportTopN)
+		if err != nil {
+			return fmt.Errorf("build coverage report: %w", err)
+		}
+
+		logger := slog.Default()
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			logger = logger.With("request_id", lc.AwsRequestID)
+		}
+		logger.InfoContext(ctx, "coverage report", "report", report)
+	}
+
+	return nil
+}
+
+// floatEnv parses an optional float environment variable, returning zero when it is unset.
+func floatEnv(name string) (float64, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// intEnv parses an optional integer environment variable, returning zero when it is unset.
+func intEnv(name string) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// parseLogLevel converts a LOG_LEVEL value such as "debug" or "WARN" into a slog level, defaulting to info.
+func parseLogLevel(raw string) (slog.Level, error) {
+	var level slog.Level
+	if raw == "" {
+		return slog.LevelInfo, nil
+	}
+
+	if err := level.UnmarshalText([]byte(raw)); err != nil {
+		return 0, fmt.Errorf("parse LOG_LEVEL: %w", err)
+	}
+	return level, nil
 }
 
 func main() {
+	level, err := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+
 	lambda.Start(handler)
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/localaws"
+)
+
+func main() {
+	addr := flag.String("addr", ":4566", "address to listen on")
+	dir := flag.String("dir", ".", "directory whose subdirectories are served as S3 buckets")
+	verbose := flag.Bool("v", false, "log every request")
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	logger.Info("serving S3 and CloudWatch", "addr", *addr, "dir", *dir)
+	if err := http.ListenAndServe(*addr, localaws.NewServer(*dir, logger)); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
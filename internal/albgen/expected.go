@@ -0,0 +1,113 @@
+package albgen
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExpectedMetrics holds the ground-truth aggregates a rule set should produce for generated requests,
+// keyed like the path metrics by Method, Host, Path (the rule name) and minute.
+type ExpectedMetrics struct {
+	Metrics []ExpectedMetric `json:"metrics"`
+
+	index map[expectedKey]*ExpectedMetric
+}
+
+// ExpectedMetric is the ground truth for a single Method/Host/Path/minute.
+type ExpectedMetric struct {
+	Method             string    `json:"method"`
+	Host               string    `json:"host"`
+	Path               string    `json:"path"`
+	Minute             time.Time `json:"minute"`
+	RequestCount       int       `json:"request_count"`
+	FailedRequestCount int       `json:"failed_request_count"`
+	// TargetResponseTimes is the multiset of non-negative target processing times, ordered by value.
+	TargetResponseTimes []ValueCount `json:"target_response_times"`
+
+	latencies map[float64]int
+}
+
+// ValueCount is a value and the number of times it was observed.
+type ValueCount struct {
+	Value float64 `json:"value"`
+	Count int     `json:"count"`
+}
+
+type expectedKey struct {
+	method string
+	host   string
+	path   string
+	minute time.Time
+}
+
+// Record adds a generated request that the rule set normalized to name.
+func (e *ExpectedMetrics) Record(req Request, name string) {
+	if e.index == nil {
+		e.index = make(map[expectedKey]*ExpectedMetric)
+	}
+
+	minute := req.Time.UTC().Truncate(time.Minute)
+	key := expectedKey{method: req.Method, host: req.Host, path: name, minute: minute}
+	m, ok := e.index[key]
+	if !ok {
+		m = &ExpectedMetric{Method: req.Method, Host: req.Host, Path: name, Minute: minute, latencies: make(map[float64]int)}
+		e.index[key] = m
+	}
+
+	m.RequestCount++
+	if req.Status >= 500 && req.Status <= 599 {
+		m.FailedRequestCount++
+	}
+	if req.TargetProcessingTime >= 0 {
+		m.latencies[req.TargetProcessingTime]++
+	}
+}
+
+// WriteFile writes the aggregates recorded so far as indented JSON, ordered by minute, host, path and method.
+func (e *ExpectedMetrics) WriteFile(path string) error {
+	e.Metrics = e.Metrics[:0]
+	for _, m := range e.index {
+		m.TargetResponseTimes = make([]ValueCount, 0, len(m.latencies))
+		for _, value := range slices.Sorted(maps.Keys(m.latencies)) {
+			m.TargetResponseTimes = append(m.TargetResponseTimes, ValueCount{Value: value, Count: m.latencies[value]})
+		}
+		e.Metrics = append(e.Metrics, *m)
+	}
+	slices.SortFunc(e.Metrics, func(a, b ExpectedMetric) int {
+		return cmp.Or(
+			a.Minute.Compare(b.Minute),
+			strings.Compare(a.Host, b.Host),
+			strings.Compare(a.Path, b.Path),
+			strings.Compare(a.Method, b.Method),
+		)
+	})
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode expected metrics: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write expected metrics: %w", err)
+	}
+	return nil
+}
+
+// LoadExpectedMetrics reads a file written by WriteFile.
+func LoadExpectedMetrics(path string) (*ExpectedMetrics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read expected metrics: %w", err)
+	}
+
+	var e ExpectedMetrics
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse expected metrics JSON: %w", err)
+	}
+	return &e, nil
+}
@@ -0,0 +1,99 @@
+// Package albgen models the fields of an ALB access log line for generating synthetic logs.
+package albgen
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Fields holds every field of an ALB access log line in log order.
+// The faker tags describe the values alb-logs-generator fills in by default.
+type Fields struct {
+	Type                   string  `faker:"oneof: https"`
+	Time                   string  `faker:"custom_alb_time"`
+	ELB                    string  `faker:"oneof: app/prod-alb/50dc6c495c0c9188"`
+	ClientIP               string  `faker:"ipv4"`
+	ClientPort             int     `faker:"boundary_start=1024, boundary_end=65535"`
+	TargetIP               string  `faker:"oneof: 192.0.2.10, 192.0.2.11, 192.0.2.12"`
+	TargetPort             string  `faker:"oneof: 8080"`
+	RequestProcessingTime  float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
+	TargetProcessingTime   float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
+	ResponseProcessingTime float64 `faker:"boundary_start=0.5, boundary_end=1.0"`
+	ELBStatusCode          int     `faker:"oneof: 200"`
+	TargetStatusCode       string  `faker:"oneof: 200"`
+	ReceivedBytes          int     `faker:"oneof: 0, 100, 500, 1000, 2000, 5000"`
+	SentBytes              int     `faker:"oneof: 0, 500, 1000, 5000, 10000, 50000"`
+	Request                string  `faker:"custom_alb_request"`
+	UserAgent              string  `faker:"user_agent"`
+	SSLCipher              string  `faker:"oneof: ECDHE-RSA-AES128-GCM-SHA256"`
+	SSLProtocol            string  `faker:"oneof: TLSv1.2, TLSv1.3"`
+	TargetGroupARN         string  `faker:"custom_alb_target_group_arn"`
+	TraceID                string  `faker:"oneof: Root=1-58337281-1d84f3d73c47ec4e58577259"`
+	DomainName             string  `faker:"oneof: www.example.com, admin.example.com"`
+	ChosenCertARN          string  `faker:"custom_alb_chosen_cert_arn"`
+	MatchedRulePriority    string  `faker:"oneof: 0, 1, 10, 100, 1000"`
+	RequestCreationTime    string  `faker:"custom_alb_time"`
+	ActionsExecuted        string  `faker:"oneof: -"`
+	RedirectURL            string  `faker:"oneof: -"`
+	ErrorReason            string  `faker:"oneof: -"`
+	PortList               int     `faker:"oneof: 8080"`
+	TargetStatusCodeList   string  `faker:"oneof: 200, 201, 204, 400, 500"`
+	Classification         string  `faker:"oneof: -"`
+	ClassificationReason   string  `faker:"oneof: -"`
+	ConnTraceID            string  `faker:"oneof: TID_1234abcd5678ef90"`
+}
+
+// String formats the fields as a single access log line.
+// A TargetIP of "-" marks a request that never reached a target, and negative durations are written as -1.
+func (f Fields) String() string {
+	return fmt.Sprintf(
+		"%s %s %s %s:%d %s %s %s %s %d %s %d %d \"%s\" \"%s\" %s %s %s \"%s\" \"%s\" \"%s\" %s %s \"%s\" \"%s\" \"%s\" \"%s\" \"%s\" \"%s\" \"%s\" %s",
+		f.Type,
+		f.Time,
+		f.ELB,
+		f.ClientIP,
+		f.ClientPort,
+		f.target(f.TargetPort),
+		formatSeconds(f.RequestProcessingTime),
+		formatSeconds(f.TargetProcessingTime),
+		formatSeconds(f.ResponseProcessingTime),
+		f.ELBStatusCode,
+		f.TargetStatusCode,
+		f.ReceivedBytes,
+		f.SentBytes,
+		f.Request,
+		f.UserAgent,
+		f.SSLCipher,
+		f.SSLProtocol,
+		f.TargetGroupARN,
+		f.TraceID,
+		f.DomainName,
+		f.ChosenCertARN,
+		f.MatchedRulePriority,
+		f.RequestCreationTime,
+		f.ActionsExecuted,
+		f.RedirectURL,
+		f.ErrorReason,
+		f.target(strconv.Itoa(f.PortList)),
+		f.TargetStatusCodeList,
+		f.Classification,
+		f.ClassificationReason,
+		f.ConnTraceID,
+	)
+}
+
+// target formats the target address with the given port, or "-" when there was no target.
+func (f Fields) target(port string) string {
+	if f.TargetIP == "-" {
+		return "-"
+	}
+	return f.TargetIP + ":" + port
+}
+
+// formatSeconds formats a duration in seconds the way ALB does, using -1 for requests without one.
+func formatSeconds(v float64) string {
+	if v < 0 {
+		return "-1"
+	}
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
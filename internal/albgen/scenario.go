@@ -0,0 +1,563 @@
+package albgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// logFileInterval is how often ALB delivers a log object per load balancer node.
+	logFileInterval = 5 * time.Minute
+
+	timeLayout = "2006-01-02T15:04:05.000000Z"
+
+	defaultDuration  = 5 * time.Minute
+	defaultRate      = 10
+	defaultAccountID = "123456789012"
+	defaultRegion    = "us-east-1"
+	defaultMedian    = 0.05
+	defaultSigma     = 0.5
+)
+
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+	"curl/8.7.1",
+	"python-requests/2.32.3",
+	"Go-http-client/2.0",
+}
+
+// Duration is a time.Duration that unmarshals from strings such as "5m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Scenario describes the traffic to simulate across one or more load balancers.
+type Scenario struct {
+	// Start is the time of the first request.
+	Start time.Time `json:"start"`
+	// Duration is how long the simulated traffic lasts. Defaults to 5m.
+	Duration Duration `json:"duration"`
+	// Rate is the number of requests per second across all load balancers. Defaults to 10.
+	Rate float64 `json:"rate"`
+	// AccountID and Region are used in object keys and ARNs.
+	AccountID string `json:"account_id"`
+	Region    string `json:"region"`
+	// Prefix is prepended to every object key, as configured on the ALB access log settings.
+	Prefix        string         `json:"prefix"`
+	LoadBalancers []LoadBalancer `json:"load_balancers"`
+	Bursts        []Burst        `json:"bursts"`
+}
+
+// LoadBalancer is a single ALB with its target groups and the endpoints it serves.
+type LoadBalancer struct {
+	Name string `json:"name"`
+	// ID is the hexadecimal suffix of the load balancer ARN. Derived from the name when empty.
+	ID string `json:"id"`
+	// Type is the request type written in the first field: http, https or h2. Defaults to https.
+	Type string `json:"type"`
+	// Weight is this load balancer's share of the scenario rate. Defaults to 1.
+	Weight       float64       `json:"weight"`
+	TargetGroups []TargetGroup `json:"target_groups"`
+	Endpoints    []Endpoint    `json:"endpoints"`
+}
+
+// TargetGroup is a set of "ip:port" targets.
+type TargetGroup struct {
+	Name    string   `json:"name"`
+	Targets []string `json:"targets"`
+	// Unreachable is the fraction of requests that find no healthy target.
+	Unreachable float64 `json:"unreachable"`
+}
+
+// Endpoint is a request pattern served by a load balancer.
+type Endpoint struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	// Path may contain {id}, {uuid} and {hex} placeholders that are filled per request.
+	Path string `json:"path"`
+	// Weight is this endpoint's share of its load balancer's traffic. Defaults to 1.
+	Weight float64 `json:"weight"`
+	// TargetGroup names the target group that serves the endpoint. Defaults to the first one.
+	TargetGroup string `json:"target_group"`
+	// Status maps status codes to relative weights. Defaults to {"200": 1}.
+	Status  map[string]float64 `json:"status"`
+	Latency Latency            `json:"latency"`
+
+	statuses []statusChoice
+}
+
+// Latency is a lognormal target processing time distribution with occasional tail spikes, in seconds.
+type Latency struct {
+	Median           float64 `json:"median"`
+	Sigma            float64 `json:"sigma"`
+	SpikeProbability float64 `json:"spike_probability"`
+	SpikeMultiplier  float64 `json:"spike_multiplier"`
+}
+
+// Burst overrides the outcome of matching requests for a window of the scenario.
+type Burst struct {
+	// Start is the offset from the scenario start.
+	Start    Duration `json:"start"`
+	Duration Duration `json:"duration"`
+	// LoadBalancer, Host and Path restrict the burst to matching requests; empty matches everything.
+	// Path is compared with the endpoint's path template.
+	LoadBalancer string `json:"load_balancer"`
+	Host         string `json:"host"`
+	Path         string `json:"path"`
+	// Probability is the fraction of matching requests affected. Defaults to 1.
+	Probability float64 `json:"probability"`
+	// Status replaces the endpoint's status mix for affected requests.
+	Status map[string]float64 `json:"status"`
+	// Unreachable is the fraction of affected requests that find no healthy target.
+	Unreachable float64 `json:"unreachable"`
+
+	statuses []statusChoice
+}
+
+// Request is a generated log line together with the values it was generated from.
+type Request struct {
+	// Key is the S3 object key the line belongs to.
+	Key    string
+	Time   time.Time
+	Method string
+	Host   string
+	Path   string
+	Status int
+	// TargetProcessingTime is -1 when no target was reached.
+	TargetProcessingTime float64
+	Fields               Fields
+}
+
+// LoadScenario reads a JSON scenario file and applies defaults.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+
+	return ParseScenario(data)
+}
+
+// ParseScenario parses a JSON scenario and applies defaults.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario JSON: %w", err)
+	}
+
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Scenario) validate() error {
+	if s.Duration == 0 {
+		s.Duration = Duration(defaultDuration)
+	}
+	if s.Rate == 0 {
+		s.Rate = defaultRate
+	}
+	if s.AccountID == "" {
+		s.AccountID = defaultAccountID
+	}
+	if s.Region == "" {
+		s.Region = defaultRegion
+	}
+	s.Prefix = strings.Trim(s.Prefix, "/")
+
+	if s.Duration < 0 || s.Rate < 0 {
+		return fmt.Errorf("duration and rate must not be negative")
+	}
+	if len(s.LoadBalancers) == 0 {
+		return fmt.Errorf("at least one load balancer is required")
+	}
+
+	for i := range s.LoadBalancers {
+		if err := s.LoadBalancers[i].validate(); err != nil {
+			return fmt.Errorf("load balancer %d: %w", i, err)
+		}
+	}
+
+	for i := range s.Bursts {
+		burst := &s.Bursts[i]
+		if burst.Duration <= 0 {
+			return fmt.Errorf("burst %d: duration is required", i)
+		}
+		if burst.Probability == 0 {
+			burst.Probability = 1
+		}
+		statuses, err := statusChoices(burst.Status)
+		if err != nil {
+			return fmt.Errorf("burst %d: %w", i, err)
+		}
+		burst.statuses = statuses
+	}
+
+	return nil
+}
+
+func (lb *LoadBalancer) validate() error {
+	if lb.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if lb.ID == "" {
+		lb.ID = hexID(lb.Name)
+	}
+	if lb.Type == "" {
+		lb.Type = "https"
+	}
+	if lb.Weight == 0 {
+		lb.Weight = 1
+	}
+	if len(lb.TargetGroups) == 0 {
+		return fmt.Errorf("at least one target group is required")
+	}
+	if len(lb.Endpoints) == 0 {
+		return fmt.Errorf("at least one endpoint is required")
+	}
+
+	for i, tg := range lb.TargetGroups {
+		if tg.Name == "" {
+			return fmt.Errorf("target group %d: name is required", i)
+		}
+		if len(tg.Targets) == 0 {
+			return fmt.Errorf("target group %d: at least one target is required", i)
+		}
+		for _, target := range tg.Targets {
+			if _, _, found := strings.Cut(target, ":"); !found {
+				return fmt.Errorf("target group %d: target %q must be ip:port", i, target)
+			}
+		}
+	}
+
+	for i := range lb.Endpoints {
+		ep := &lb.Endpoints[i]
+		if ep.Method == "" || ep.Host == "" || !strings.HasPrefix(ep.Path, "/") {
+			return fmt.Errorf("endpoint %d: method, host and a path starting with / are required", i)
+		}
+		if ep.Weight == 0 {
+			ep.Weight = 1
+		}
+		if ep.TargetGroup == "" {
+			ep.TargetGroup = lb.TargetGroups[0].Name
+		}
+		if lb.targetGroup(ep.TargetGroup) == nil {
+			return fmt.Errorf("endpoint %d: unknown target group %q", i, ep.TargetGroup)
+		}
+		if len(ep.Status) == 0 {
+			ep.Status = map[string]float64{"200": 1}
+		}
+		statuses, err := statusChoices(ep.Status)
+		if err != nil {
+			return fmt.Errorf("endpoint %d: %w", i, err)
+		}
+		ep.statuses = statuses
+		if ep.Latency.Median == 0 {
+			ep.Latency.Median = defaultMedian
+		}
+		if ep.Latency.Sigma == 0 {
+			ep.Latency.Sigma = defaultSigma
+		}
+	}
+
+	return nil
+}
+
+func (lb *LoadBalancer) targetGroup(name string) *TargetGroup {
+	for i := range lb.TargetGroups {
+		if lb.TargetGroups[i].Name == name {
+			return &lb.TargetGroups[i]
+		}
+	}
+	return nil
+}
+
+type statusChoice struct {
+	status int
+	weight float64
+}
+
+// statusChoices converts a status weight map into a slice ordered by status code so that picks are reproducible.
+func statusChoices(weights map[string]float64) ([]statusChoice, error) {
+	choices := make([]statusChoice, 0, len(weights))
+	for code, weight := range weights {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 100 || status > 599 {
+			return nil, fmt.Errorf("invalid status code %q", code)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("status %s: weight must not be negative", code)
+		}
+		choices = append(choices, statusChoice{status: status, weight: weight})
+	}
+
+	slices.SortFunc(choices, func(a, b statusChoice) int { return a.status - b.status })
+	return choices, nil
+}
+
+// Generate produces the scenario's requests in time order and passes each to emit.
+// The same scenario and seed always produce the same requests.
+func (s *Scenario) Generate(seed uint64, emit func(Request) error) error {
+	g := &generator{
+		scenario: s,
+		rng:      rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		keys:     make(map[objectWindow]string),
+	}
+
+	total := int(s.Rate * time.Duration(s.Duration).Seconds())
+	if total == 0 {
+		return nil
+	}
+	interval := time.Duration(s.Duration) / time.Duration(total)
+
+	for i := range total {
+		ts := s.Start.Add(time.Duration(i) * interval)
+		if interval > 1 {
+			ts = ts.Add(time.Duration(g.rng.Int64N(int64(interval))))
+		}
+
+		if err := emit(g.request(ts.UTC())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type objectWindow struct {
+	loadBalancer int
+	end          time.Time
+}
+
+type generator struct {
+	scenario *Scenario
+	rng      *rand.Rand
+	keys     map[objectWindow]string
+}
+
+func (g *generator) request(ts time.Time) Request {
+	s := g.scenario
+	lbIdx := g.pickWeighted(len(s.LoadBalancers), func(i int) float64 { return s.LoadBalancers[i].Weight })
+	lb := &s.LoadBalancers[lbIdx]
+	ep := &lb.Endpoints[g.pickWeighted(len(lb.Endpoints), func(i int) float64 { return lb.Endpoints[i].Weight })]
+	tg := lb.targetGroup(ep.TargetGroup)
+
+	statuses := ep.statuses
+	unreachable := g.rng.Float64() < tg.Unreachable
+	if burst := g.activeBurst(ts, lb, ep); burst != nil && g.rng.Float64() < burst.Probability {
+		if len(burst.statuses) > 0 {
+			statuses = burst.statuses
+		}
+		unreachable = unreachable || g.rng.Float64() < burst.Unreachable
+	}
+
+	status := statuses[g.pickWeighted(len(statuses), func(i int) float64 { return statuses[i].weight })].status
+	path := g.expandPath(ep.Path)
+
+	fields := Fields{
+		Type:                 lb.Type,
+		Time:                 ts.Format(timeLayout),
+		ELB:                  "app/" + lb.Name + "/" + lb.ID,
+		ClientIP:             fmt.Sprintf("198.51.100.%d", 1+g.rng.IntN(254)),
+		ClientPort:           1024 + g.rng.IntN(64512),
+		ELBStatusCode:        status,
+		TargetStatusCode:     strconv.Itoa(status),
+		TargetStatusCodeList: strconv.Itoa(status),
+		ReceivedBytes:        200 + g.rng.IntN(2000),
+		SentBytes:            200 + g.rng.IntN(50000),
+		Request:              requestLine(lb.Type, ep.Method, ep.Host, path),
+		UserAgent:            userAgents[g.rng.IntN(len(userAgents))],
+		SSLCipher:            "-",
+		SSLProtocol:          "-",
+		TargetGroupARN:       fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:targetgroup/%s/%s", s.Region, s.AccountID, tg.Name, hexID(tg.Name)),
+		TraceID:              fmt.Sprintf("Root=1-%08x-%012x%012x", ts.Unix(), g.rng.Uint64()>>16, g.rng.Uint64()>>16),
+		DomainName:           ep.Host,
+		ChosenCertARN:        "-",
+		MatchedRulePriority:  "1",
+		ActionsExecuted:      "forward",
+		RedirectURL:          "-",
+		ErrorReason:          "-",
+		Classification:       "-",
+		ClassificationReason: "-",
+		ConnTraceID:          fmt.Sprintf("TID_%016x", g.rng.Uint64()),
+	}
+
+	if lb.Type != "http" {
+		fields.SSLCipher, fields.SSLProtocol = "ECDHE-RSA-AES128-GCM-SHA256", "TLSv1.2"
+		if g.rng.IntN(2) == 0 {
+			fields.SSLCipher, fields.SSLProtocol = "TLS_AES_128_GCM_SHA256", "TLSv1.3"
+		}
+		fields.ChosenCertARN = fmt.Sprintf("arn:aws:acm:%s:%s:certificate/%s", s.Region, s.AccountID, "12345678-1234-1234-1234-123456789012")
+	}
+
+	var elapsed float64
+	if unreachable {
+		fields.TargetIP = "-"
+		fields.RequestProcessingTime, fields.TargetProcessingTime, fields.ResponseProcessingTime = -1, -1, -1
+		fields.TargetStatusCode, fields.TargetStatusCodeList = "-", "-"
+		if status < 500 {
+			fields.ELBStatusCode = 503
+		}
+	} else {
+		target := tg.Targets[g.rng.IntN(len(tg.Targets))]
+		ip, port, _ := strings.Cut(target, ":")
+		fields.TargetIP, fields.TargetPort = ip, port
+		fields.PortList, _ = strconv.Atoi(port)
+		fields.RequestProcessingTime = float64(g.rng.IntN(3)) / 1000
+		fields.TargetProcessingTime = g.latency(ep.Latency)
+		fields.ResponseProcessingTime = float64(g.rng.IntN(2)) / 1000
+		elapsed = fields.RequestProcessingTime + fields.TargetProcessingTime + fields.ResponseProcessingTime
+	}
+	fields.RequestCreationTime = ts.Add(-time.Duration(elapsed * float64(time.Second))).Format(timeLayout)
+
+	return Request{
+		Key:                  g.objectKey(lbIdx, ts),
+		Time:                 ts,
+		Method:               ep.Method,
+		Host:                 ep.Host,
+		Path:                 path,
+		Status:               fields.ELBStatusCode,
+		TargetProcessingTime: fields.TargetProcessingTime,
+		Fields:               fields,
+	}
+}
+
+// activeBurst returns the first burst covering ts that applies to the endpoint.
+func (g *generator) activeBurst(ts time.Time, lb *LoadBalancer, ep *Endpoint) *Burst {
+	offset := ts.Sub(g.scenario.Start)
+	for i := range g.scenario.Bursts {
+		burst := &g.scenario.Bursts[i]
+		if offset < time.Duration(burst.Start) || offset >= time.Duration(burst.Start+burst.Duration) {
+			continue
+		}
+		if (burst.LoadBalancer == "" || burst.LoadBalancer == lb.Name) &&
+			(burst.Host == "" || burst.Host == ep.Host) &&
+			(burst.Path == "" || burst.Path == ep.Path) {
+			return burst
+		}
+	}
+	return nil
+}
+
+// latency draws a target processing time rounded to the millisecond precision ALB logs.
+func (g *generator) latency(l Latency) float64 {
+	v := l.Median * math.Exp(l.Sigma*g.rng.NormFloat64())
+	if l.SpikeProbability > 0 && g.rng.Float64() < l.SpikeProbability {
+		v *= max(l.SpikeMultiplier, 1)
+	}
+	return math.Round(v*1000) / 1000
+}
+
+// pickWeighted returns an index in [0, n) with probability proportional to weight(i).
+func (g *generator) pickWeighted(n int, weight func(int) float64) int {
+	var total float64
+	for i := range n {
+		total += weight(i)
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	r := g.rng.Float64() * total
+	for i := range n {
+		r -= weight(i)
+		if r < 0 {
+			return i
+		}
+	}
+	return n - 1
+}
+
+// expandPath fills the {id}, {uuid} and {hex} placeholders in a path template.
+func (g *generator) expandPath(template string) string {
+	var b strings.Builder
+	rest := template
+	for {
+		before, after, found := strings.Cut(rest, "{")
+		b.WriteString(before)
+		if !found {
+			return b.String()
+		}
+
+		name, remainder, closed := strings.Cut(after, "}")
+		if !closed {
+			b.WriteString("{" + after)
+			return b.String()
+		}
+
+		switch name {
+		case "id":
+			b.WriteString(strconv.Itoa(1 + g.rng.IntN(100000)))
+		case "uuid":
+			hi, lo := g.rng.Uint64(), g.rng.Uint64()
+			fmt.Fprintf(&b, "%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+		case "hex":
+			fmt.Fprintf(&b, "%016x", g.rng.Uint64())
+		default:
+			b.WriteString("{" + name + "}")
+		}
+		rest = remainder
+	}
+}
+
+// objectKey returns the key of the object ALB would deliver the request in, following the
+// AWSLogs/<account>/elasticloadbalancing/<region>/yyyy/mm/dd/ layout.
+func (g *generator) objectKey(lbIdx int, ts time.Time) string {
+	s := g.scenario
+	window := objectWindow{loadBalancer: lbIdx, end: ts.Truncate(logFileInterval).Add(logFileInterval)}
+	if key, ok := g.keys[window]; ok {
+		return key
+	}
+
+	lb := s.LoadBalancers[lbIdx]
+	key := fmt.Sprintf("AWSLogs/%s/elasticloadbalancing/%s/%s/%s_elasticloadbalancing_%s_app.%s.%s_%s_10.0.%d.10_%08x.log.gz",
+		s.AccountID, s.Region, window.end.Format("2006/01/02"),
+		s.AccountID, s.Region, lb.Name, lb.ID, window.end.Format("20060102T1504Z"), lbIdx%256, g.rng.Uint32())
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+
+	g.keys[window] = key
+	return key
+}
+
+// requestLine formats the request field for the load balancer's listener protocol.
+func requestLine(typ, method, host, path string) string {
+	if typ == "http" {
+		return fmt.Sprintf("%s http://%s:80%s HTTP/1.1", method, host, path)
+	}
+	if typ == "h2" {
+		return fmt.Sprintf("%s https://%s:443%s HTTP/2.0", method, host, path)
+	}
+	return fmt.Sprintf("%s https://%s:443%s HTTP/1.1", method, host, path)
+}
+
+// hexID derives a stable 16 character hexadecimal ID from a name.
+func hexID(name string) string {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,120 @@
+package albgen
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScenario = `{
+	"start": "2024-01-15T10:00:00Z",
+	"duration": "10m",
+	"rate": 5,
+	"load_balancers": [
+		{
+			"name": "prod-alb",
+			"target_groups": [{"name": "api", "targets": ["10.0.1.10:8080"]}],
+			"endpoints": [
+				{"method": "GET", "host": "api.example.com", "path": "/users/{id}", "status": {"200": 9, "404": 1}},
+				{"method": "POST", "host": "api.example.com", "path": "/orders", "latency": {"median": 0.2, "sigma": 0.1}}
+			]
+		},
+		{
+			"name": "admin-alb",
+			"type": "http",
+			"target_groups": [{"name": "admin", "targets": ["10.0.2.10:80"], "unreachable": 1}],
+			"endpoints": [{"method": "GET", "host": "admin.example.com", "path": "/"}]
+		}
+	],
+	"bursts": [
+		{"start": "5m", "duration": "1m", "path": "/orders", "status": {"502": 1}}
+	]
+}`
+
+func generate(t *testing.T, raw string, seed uint64) []Request {
+	t.Helper()
+
+	scenario, err := ParseScenario([]byte(raw))
+	require.NoError(t, err)
+
+	var requests []Request
+	require.NoError(t, scenario.Generate(seed, func(req Request) error {
+		requests = append(requests, req)
+		return nil
+	}))
+	return requests
+}
+
+func TestScenario_GenerateIsReproducible(t *testing.T) {
+	first := generate(t, testScenario, 42)
+	second := generate(t, testScenario, 42)
+	other := generate(t, testScenario, 43)
+
+	require.Len(t, first, 3000)
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, other)
+}
+
+func TestScenario_Generate(t *testing.T) {
+	requests := generate(t, testScenario, 1)
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	keyPattern := regexp.MustCompile(`^AWSLogs/123456789012/elasticloadbalancing/us-east-1/2024/01/15/` +
+		`123456789012_elasticloadbalancing_us-east-1_app\.(prod|admin)-alb\.[0-9a-f]{16}_20240115T10(05|10)Z_10\.0\.[01]\.10_[0-9a-f]{8}\.log\.gz$`)
+
+	for i, req := range requests {
+		if i > 0 {
+			assert.False(t, req.Time.Before(requests[i-1].Time), "requests are in time order")
+		}
+		assert.Regexp(t, keyPattern, req.Key)
+
+		offset := req.Time.Sub(start)
+		switch {
+		case req.Host == "admin.example.com":
+			assert.Equal(t, 503, req.Status)
+			assert.Equal(t, float64(-1), req.TargetProcessingTime)
+			assert.Contains(t, req.Fields.String(), " - -1 -1 -1 503 - ")
+			assert.Contains(t, req.Fields.Request, "http://admin.example.com:80/ ")
+		case req.Path == "/orders" && offset >= 5*time.Minute && offset < 6*time.Minute:
+			assert.Equal(t, 502, req.Status)
+		case req.Path == "/orders":
+			assert.Equal(t, 200, req.Status)
+			assert.Greater(t, req.TargetProcessingTime, 0.0)
+		default:
+			assert.Regexp(t, `^/users/[0-9]+$`, req.Path)
+			assert.Contains(t, []int{200, 404}, req.Status)
+		}
+	}
+}
+
+func TestParseScenario_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{name: "no load balancers", json: `{}`},
+		{name: "missing name", json: `{"load_balancers":[{"target_groups":[{"name":"a","targets":["10.0.0.1:80"]}],"endpoints":[{"method":"GET","host":"h","path":"/"}]}]}`},
+		{name: "unknown target group", json: `{"load_balancers":[{"name":"lb","target_groups":[{"name":"a","targets":["10.0.0.1:80"]}],"endpoints":[{"method":"GET","host":"h","path":"/","target_group":"b"}]}]}`},
+		{name: "target without port", json: `{"load_balancers":[{"name":"lb","target_groups":[{"name":"a","targets":["10.0.0.1"]}],"endpoints":[{"method":"GET","host":"h","path":"/"}]}]}`},
+		{name: "invalid status", json: `{"load_balancers":[{"name":"lb","target_groups":[{"name":"a","targets":["10.0.0.1:80"]}],"endpoints":[{"method":"GET","host":"h","path":"/","status":{"ok":1}}]}]}`},
+		{name: "invalid duration", json: `{"duration":"soon","load_balancers":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScenario([]byte(tt.json))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGenerator_ExpandPath(t *testing.T) {
+	g := &generator{rng: rand.New(rand.NewPCG(1, 2))}
+
+	assert.Regexp(t, `^/users/[0-9]+/orders/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, g.expandPath("/users/{id}/orders/{uuid}"))
+	assert.Regexp(t, `^/assets/[0-9a-f]{16}\.js$`, g.expandPath("/assets/{hex}.js"))
+	assert.Equal(t, "/{other}/{open", g.expandPath("/{other}/{open"))
+}
@@ -0,0 +1,138 @@
+// Package cwgen generates CloudWatch alarms and dashboards for the metrics the processor publishes.
+//
+// The types mirror the PutMetricAlarm, PutCompositeAlarm and PutDashboard request shapes, so their JSON
+// can be passed to the AWS CLI with --cli-input-json. Resources can also be rendered as CloudFormation
+// templates and Terraform configurations.
+package cwgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Options configures the generated resources.
+type Options struct {
+	// Namespace is the namespace the metrics are published to.
+	Namespace string
+	// Region is the region of the metrics, required by dashboard widgets.
+	Region string
+	// AlarmPrefix is prepended to every alarm name.
+	AlarmPrefix string
+	// AlarmActions are notified by the alarms that page, such as SNS topic ARNs.
+	AlarmActions []string
+}
+
+// MetricAlarm is the input of PutMetricAlarm for an alarm on a metric math expression.
+type MetricAlarm struct {
+	AlarmName          string            `json:"AlarmName"`
+	AlarmDescription   string            `json:"AlarmDescription,omitempty"`
+	AlarmActions       []string          `json:"AlarmActions,omitempty"`
+	Metrics            []MetricDataQuery `json:"Metrics"`
+	EvaluationPeriods  int               `json:"EvaluationPeriods"`
+	Threshold          float64           `json:"Threshold"`
+	ComparisonOperator string            `json:"ComparisonOperator"`
+	TreatMissingData   string            `json:"TreatMissingData"`
+}
+
+// MetricDataQuery is a metric or expression of a MetricAlarm.
+type MetricDataQuery struct {
+	ID         string      `json:"Id"`
+	Expression string      `json:"Expression,omitempty"`
+	Label      string      `json:"Label,omitempty"`
+	MetricStat *MetricStat `json:"MetricStat,omitempty"`
+	// ReturnData is always written, since CloudWatch treats a missing value as true.
+	ReturnData bool `json:"ReturnData"`
+}
+
+// MetricStat selects a metric and its statistic.
+type MetricStat struct {
+	Metric Metric `json:"Metric"`
+	Period int    `json:"Period"`
+	Stat   string `json:"Stat"`
+}
+
+// Metric identifies a metric.
+type Metric struct {
+	Namespace  string      `json:"Namespace"`
+	MetricName string      `json:"MetricName"`
+	Dimensions []Dimension `json:"Dimensions"`
+}
+
+// Dimension is a metric dimension.
+type Dimension struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+// CompositeAlarm is the input of PutCompositeAlarm.
+type CompositeAlarm struct {
+	AlarmName        string   `json:"AlarmName"`
+	AlarmDescription string   `json:"AlarmDescription,omitempty"`
+	AlarmRule        string   `json:"AlarmRule"`
+	AlarmActions     []string `json:"AlarmActions,omitempty"`
+}
+
+// Alarms holds generated alarms. Composite alarms refer to metric alarms by name, so the metric alarms
+// must be created first.
+type Alarms struct {
+	MetricAlarms    []MetricAlarm    `json:"MetricAlarms"`
+	CompositeAlarms []CompositeAlarm `json:"CompositeAlarms"`
+}
+
+// Dashboard is the input of PutDashboard.
+type Dashboard struct {
+	DashboardName string `json:"DashboardName"`
+	// DashboardBody is the JSON encoding of a dashboardBody.
+	DashboardBody string `json:"DashboardBody"`
+}
+
+// rowHeight is the height of a dashboard row.
+const rowHeight = 6
+
+// dashboardBody is the dashboard body structure; see the CloudWatch dashboard body reference.
+type dashboardBody struct {
+	Widgets []widget `json:"widgets"`
+}
+
+type widget struct {
+	Type       string           `json:"type"`
+	X          int              `json:"x"`
+	Y          int              `json:"y"`
+	Width      int              `json:"width"`
+	Height     int              `json:"height"`
+	Properties widgetProperties `json:"properties"`
+}
+
+type widgetProperties struct {
+	Title                string       `json:"title"`
+	Region               string       `json:"region"`
+	View                 string       `json:"view"`
+	Stat                 string       `json:"stat,omitempty"`
+	Period               int          `json:"period"`
+	Start                string       `json:"start,omitempty"`
+	SetPeriodToTimeRange bool         `json:"setPeriodToTimeRange,omitempty"`
+	Metrics              [][]any      `json:"metrics"`
+	Annotations          *annotations `json:"annotations,omitempty"`
+}
+
+type annotations struct {
+	Horizontal []annotation `json:"horizontal"`
+}
+
+type annotation struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+// newDashboard encodes the widgets as the body of a dashboard.
+func newDashboard(name string, widgets []widget) (Dashboard, error) {
+	// Metric math such as "total > 0" stays readable without HTML escaping.
+	var body strings.Builder
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(dashboardBody{Widgets: widgets}); err != nil {
+		return Dashboard{}, fmt.Errorf("encode dashboard body: %w", err)
+	}
+	return Dashboard{DashboardName: name, DashboardBody: strings.TrimSuffix(body.String(), "\n")}, nil
+}
@@ -0,0 +1,167 @@
+package cwgen
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+// pathPeriod is the period of the path widgets, matching the minute the metrics are aggregated over.
+const pathPeriod = 60
+
+// latencyPercentiles are the percentiles of the latency widgets.
+var latencyPercentiles = []string{"p50", "p90", "p99"}
+
+// Resources are the dashboard and alarms generated for a rule set.
+type Resources struct {
+	Dashboard Dashboard `json:"Dashboard"`
+	Alarms
+}
+
+// Generate returns a dashboard with a row per published path followed by a row per SLO, and the
+// burn-rate alarms of the SLOs. Every SLO must refer to a published path, so that rules, dashboards and
+// alarms cannot drift apart.
+func Generate(name string, paths []metrics.PublishedPath, slos []metrics.SLO, opts Options) (Resources, error) {
+	if len(paths) == 0 {
+		return Resources{}, fmt.Errorf("no paths: the rules must publish at least one path")
+	}
+	if err := checkSLOPaths(paths, slos); err != nil {
+		return Resources{}, err
+	}
+
+	widgets := pathWidgets(paths, opts, 0)
+	widgets = append(widgets, sloWidgets(slos, opts, len(paths)*rowHeight)...)
+	dashboard, err := newDashboard(name, widgets)
+	if err != nil {
+		return Resources{}, err
+	}
+
+	return Resources{Dashboard: dashboard, Alarms: SLOAlarms(slos, opts)}, nil
+}
+
+// checkSLOPaths checks that every SLO refers to a path the rules publish.
+func checkSLOPaths(paths []metrics.PublishedPath, slos []metrics.SLO) error {
+	for _, slo := range slos {
+		found := false
+		for _, path := range paths {
+			if path.Path == slo.Path && (slo.Host == "" || slo.Host == path.Host) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("SLO %q: no rule publishes path %q", slo.Name, slo.Path)
+		}
+	}
+	return nil
+}
+
+// pathWidgets returns a row per path, starting at row y, with its request rate, error rate and latency
+// percentiles. Widgets for metrics the rule does not publish are left out, and a latency published under
+// several names gets a widget per name that share the latency column.
+func pathWidgets(paths []metrics.PublishedPath, opts Options, y int) []widget {
+	var widgets []widget
+	for _, path := range paths {
+		title := describePath(path)
+		requests, hasRequests := firstPublished(path, metrics.MetricNameRequestCount)
+		failed, hasFailed := firstPublished(path, metrics.MetricNameFailedRequestCount)
+		latencies := published(path, metrics.MetricNameTargetResponseTime)
+
+		if hasRequests {
+			widgets = append(widgets, pathWidget(title+": requests/min", 0, y, 8, opts, [][]any{
+				{map[string]any{"id": "requests", "expression": "SUM(" + searchExpression(opts, path, requests.Name, "Sum") + ")", "label": "Requests"}},
+			}))
+		}
+
+		if hasRequests && hasFailed {
+			widgets = append(widgets, pathWidget(title+": error rate (%)", 8, y, 8, opts, [][]any{
+				{map[string]any{"id": "error_rate", "expression": "100 * FILL(failed, 0) / requests", "label": "Error rate (%)"}},
+				{map[string]any{"id": "failed", "expression": "SUM(" + searchExpression(opts, path, failed.Name, "Sum") + ")", "visible": false}},
+				{map[string]any{"id": "requests", "expression": "SUM(" + searchExpression(opts, path, requests.Name, "Sum") + ")", "visible": false}},
+			}))
+		}
+
+		width := max(8/max(len(latencies), 1), 1)
+		for idx, latency := range latencies {
+			var queries [][]any
+			for _, stat := range latencyPercentiles {
+				label := stat
+				if path.Method == "" {
+					// Percentiles cannot be combined across methods, so each method gets its own line.
+					label += " ${PROP('Dim." + metrics.MetricDimensionMethod + "')}"
+				}
+				queries = append(queries, []any{map[string]any{"id": stat, "expression": searchExpression(opts, path, latency.Name, stat), "label": label}})
+			}
+			latencyTitle := fmt.Sprintf("%s: latency (%s)", title, latency.Unit)
+			if len(latencies) > 1 {
+				latencyTitle = fmt.Sprintf("%s: %s (%s)", title, latency.Name, latency.Unit)
+			}
+			widgets = append(widgets, pathWidget(latencyTitle, 16+idx*width, y, width, opts, queries))
+		}
+
+		y += rowHeight
+	}
+	return widgets
+}
+
+func pathWidget(title string, x, y, width int, opts Options, queries [][]any) widget {
+	return widget{Type: "metric", X: x, Y: y, Width: width, Height: rowHeight, Properties: widgetProperties{
+		Title:   title,
+		Region:  opts.Region,
+		View:    "timeSeries",
+		Period:  pathPeriod,
+		Metrics: queries,
+	}}
+}
+
+// published returns the metrics the path publishes for a built-in metric, sorted by published name.
+func published(path metrics.PublishedPath, metric string) []metrics.PublishedMetric {
+	var found []metrics.PublishedMetric
+	for _, name := range slices.Sorted(maps.Keys(path.Metrics)) {
+		if path.Metrics[name].Metric == metric {
+			found = append(found, path.Metrics[name])
+		}
+	}
+	return found
+}
+
+// firstPublished returns the first metric published for a built-in metric. Counters have the same value
+// under every name, so any one of them will do.
+func firstPublished(path metrics.PublishedPath, metric string) (metrics.PublishedMetric, bool) {
+	found := published(path, metric)
+	if len(found) == 0 {
+		return metrics.PublishedMetric{}, false
+	}
+	return found[0], true
+}
+
+// describePath describes a path such as "GET example.com/users/:id".
+func describePath(path metrics.PublishedPath) string {
+	return strings.TrimSpace(path.Method + " " + path.Host + path.Path)
+}
+
+// searchExpression returns a SEARCH expression for a metric of a path. It searches the Method, Host and
+// Path schema alone so that target and client breakdowns of the same metric are not counted twice, and
+// matches every method when the rule matches any method.
+func searchExpression(opts Options, path metrics.PublishedPath, metricName, stat string) string {
+	terms := []string{
+		"MetricName=" + quoteSearchTerm(metricName),
+		metrics.MetricDimensionHost + "=" + quoteSearchTerm(path.Host),
+		metrics.MetricDimensionPath + "=" + quoteSearchTerm(path.Path),
+	}
+	if path.Method != "" {
+		terms = append(terms, metrics.MetricDimensionMethod+"="+quoteSearchTerm(path.Method))
+	}
+
+	schema := strings.Join([]string{quoteSearchTerm(opts.Namespace), metrics.MetricDimensionHost, metrics.MetricDimensionMethod, metrics.MetricDimensionPath}, ",")
+	return fmt.Sprintf("SEARCH('{%s} %s', '%s', %d)", schema, strings.Join(terms, " "), stat, pathPeriod)
+}
+
+// quoteSearchTerm quotes a search term for an exact match, escaping the quotes of the search expression.
+func quoteSearchTerm(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, `\'`).Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,116 @@
+package cwgen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func testPaths(t *testing.T) []metrics.PublishedPath {
+	t.Helper()
+
+	rules, err := metrics.NewPathRules(`[
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"example.com","pattern":"^/checkout$","name":"/checkout","metrics":["RequestCount",{"metric":"TargetResponseTime","name":"Latency","unit":"Milliseconds"}]}
+	]`)
+	require.NoError(t, err)
+	return rules.PublishedPaths(nil)
+}
+
+type testDashboardBody struct {
+	Widgets []struct {
+		X          int `json:"x"`
+		Y          int `json:"y"`
+		Properties struct {
+			Title   string  `json:"title"`
+			Metrics [][]any `json:"metrics"`
+		} `json:"properties"`
+	} `json:"widgets"`
+}
+
+func TestGenerate(t *testing.T) {
+	resources, err := Generate("alb-paths", testPaths(t), testSLOs(t), Options{Namespace: "ALBAccessLog", Region: "us-east-1"})
+	require.NoError(t, err)
+
+	assert.Len(t, resources.MetricAlarms, 8)
+	assert.Len(t, resources.CompositeAlarms, 4)
+
+	var body testDashboardBody
+	require.NoError(t, json.Unmarshal([]byte(resources.Dashboard.DashboardBody), &body))
+
+	var titles []string
+	for _, w := range body.Widgets {
+		titles = append(titles, w.Properties.Title)
+	}
+	assert.Equal(t, []string{
+		"GET example.com/users/:id: requests/min",
+		"GET example.com/users/:id: error rate (%)",
+		"GET example.com/users/:id: latency (Seconds)",
+		// The rule publishes no FailedRequestCount, so it has no error rate.
+		"example.com/checkout: requests/min",
+		"example.com/checkout: latency (Milliseconds)",
+		"users-availability: SLI (99.9% available)",
+		"users-availability: burn rate (1h)",
+		"users-availability: error budget consumed (30 days)",
+		"checkout-latency: SLI (99% within 300ms)",
+		"checkout-latency: burn rate (1h)",
+		"checkout-latency: error budget consumed (28 days)",
+	}, titles)
+
+	assert.Equal(t, 6, body.Widgets[3].Y)
+	assert.Equal(t, 16, body.Widgets[4].X)
+	assert.Equal(t, 12, body.Widgets[5].Y)
+
+	requests := body.Widgets[0].Properties.Metrics[0][0].(map[string]any)
+	assert.Equal(t, `SUM(SEARCH('{"ALBAccessLog",Host,Method,Path} MetricName="RequestCount" Host="example.com" Path="/users/:id" Method="GET"', 'Sum', 60))`, requests["expression"])
+
+	p99 := body.Widgets[4].Properties.Metrics[2][0].(map[string]any)
+	assert.Equal(t, `SEARCH('{"ALBAccessLog",Host,Method,Path} MetricName="Latency" Host="example.com" Path="/checkout"', 'p99', 60)`, p99["expression"])
+	assert.Equal(t, "p99 ${PROP('Dim.Method')}", p99["label"])
+}
+
+func TestGenerate_LatencyPublishedTwice(t *testing.T) {
+	rules, err := metrics.NewPathRules(`[
+		{"host":"example.com","pattern":"^/checkout$","name":"/checkout","metrics":["RequestCount","TargetResponseTime",{"metric":"TargetResponseTime","name":"TargetResponseTimeMs","unit":"Milliseconds"}]}
+	]`)
+	require.NoError(t, err)
+
+	resources, err := Generate("alb-paths", rules.PublishedPaths(nil), nil, Options{Namespace: "ALBAccessLog", Region: "us-east-1"})
+	require.NoError(t, err)
+
+	var body testDashboardBody
+	require.NoError(t, json.Unmarshal([]byte(resources.Dashboard.DashboardBody), &body))
+
+	require.Len(t, body.Widgets, 3)
+	assert.Equal(t, "example.com/checkout: TargetResponseTime (Seconds)", body.Widgets[1].Properties.Title)
+	assert.Equal(t, 16, body.Widgets[1].X)
+	assert.Equal(t, "example.com/checkout: TargetResponseTimeMs (Milliseconds)", body.Widgets[2].Properties.Title)
+	assert.Equal(t, 20, body.Widgets[2].X)
+
+	p99 := body.Widgets[2].Properties.Metrics[2][0].(map[string]any)
+	assert.Equal(t, `SEARCH('{"ALBAccessLog",Host,Method,Path} MetricName="TargetResponseTimeMs" Host="example.com" Path="/checkout"', 'p99', 60)`, p99["expression"])
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	slos, err := metrics.ParseSLOs(`[{"name":"orders","path":"/orders","objective":99}]`)
+	require.NoError(t, err)
+	_, err = Generate("alb-paths", testPaths(t), slos, Options{Namespace: "ALBAccessLog", Region: "us-east-1"})
+	assert.EqualError(t, err, `SLO "orders": no rule publishes path "/orders"`)
+
+	slos, err = metrics.ParseSLOs(`[{"name":"checkout","path":"/checkout","host":"shop.example.com","objective":99}]`)
+	require.NoError(t, err)
+	_, err = Generate("alb-paths", testPaths(t), slos, Options{Namespace: "ALBAccessLog", Region: "us-east-1"})
+	assert.EqualError(t, err, `SLO "checkout": no rule publishes path "/checkout"`)
+
+	_, err = Generate("alb-paths", nil, nil, Options{})
+	assert.ErrorContains(t, err, "no paths")
+}
+
+func TestQuoteSearchTerm(t *testing.T) {
+	assert.Equal(t, `"/users/:id"`, quoteSearchTerm("/users/:id"))
+	assert.Equal(t, `"/it\'s/\"quoted\"/a\\b"`, quoteSearchTerm(`/it's/"quoted"/a\b`))
+}
@@ -0,0 +1,188 @@
+package cwgen
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+// burnRateWindow is a multi-window burn-rate alert: it fires when both windows burn faster than the rate
+// that spends budget of the error budget in long.
+type burnRateWindow struct {
+	name   string
+	long   time.Duration
+	short  time.Duration
+	budget float64
+}
+
+// burnRateWindows are the fast and slow burn alerts recommended by the Google SRE workbook.
+var burnRateWindows = []burnRateWindow{
+	{name: "fast-burn", long: time.Hour, short: 5 * time.Minute, budget: 0.02},
+	{name: "slow-burn", long: 6 * time.Hour, short: 30 * time.Minute, budget: 0.05},
+}
+
+// threshold returns the burn rate at which the window spends its share of the budget of an SLO.
+func (w burnRateWindow) threshold(slo metrics.SLO) float64 {
+	window := time.Duration(slo.WindowDays) * 24 * time.Hour
+	return w.budget * window.Hours() / w.long.Hours()
+}
+
+// SLOAlarms returns, per SLO, a metric alarm on the burn rate of each window and a composite alarm per
+// burn-rate window that fires when both its long and short window burn too fast.
+func SLOAlarms(slos []metrics.SLO, opts Options) Alarms {
+	alarms := Alarms{MetricAlarms: []MetricAlarm{}, CompositeAlarms: []CompositeAlarm{}}
+	for _, slo := range slos {
+		for _, w := range burnRateWindows {
+			threshold := w.threshold(slo)
+			long := burnRateAlarm(slo, opts, w.long, threshold)
+			short := burnRateAlarm(slo, opts, w.short, threshold)
+			alarms.MetricAlarms = append(alarms.MetricAlarms, long, short)
+
+			alarms.CompositeAlarms = append(alarms.CompositeAlarms, CompositeAlarm{
+				AlarmName: opts.AlarmPrefix + slo.Name + "-" + w.name,
+				AlarmDescription: fmt.Sprintf("SLO %s (%s) is spending %.0f%% of its %d-day error budget within %s",
+					slo.Name, describeObjective(slo), w.budget*100, slo.WindowDays, formatWindow(w.long)),
+				AlarmRule:    fmt.Sprintf("ALARM(%q) AND ALARM(%q)", long.AlarmName, short.AlarmName),
+				AlarmActions: opts.AlarmActions,
+			})
+		}
+	}
+	return alarms
+}
+
+// burnRateAlarm returns an alarm on the burn rate of an SLO over window.
+func burnRateAlarm(slo metrics.SLO, opts Options, window time.Duration, threshold float64) MetricAlarm {
+	period := int(window.Seconds())
+	return MetricAlarm{
+		AlarmName:        opts.AlarmPrefix + slo.Name + "-burn-rate-" + formatWindow(window),
+		AlarmDescription: fmt.Sprintf("Burn rate of SLO %s over %s", slo.Name, formatWindow(window)),
+		Metrics: []MetricDataQuery{
+			{ID: "good", MetricStat: sloMetricStat(slo, opts, metrics.MetricNameSLOGoodCount, period)},
+			{ID: "total", MetricStat: sloMetricStat(slo, opts, metrics.MetricNameSLOTotalCount, period)},
+			{ID: "burn_rate", Expression: burnRateExpression(slo), Label: "Burn rate", ReturnData: true},
+		},
+		EvaluationPeriods:  1,
+		Threshold:          roundThreshold(threshold),
+		ComparisonOperator: "GreaterThanThreshold",
+		TreatMissingData:   "notBreaching",
+	}
+}
+
+func sloMetricStat(slo metrics.SLO, opts Options, metricName string, period int) *MetricStat {
+	return &MetricStat{
+		Metric: Metric{
+			Namespace:  opts.Namespace,
+			MetricName: metricName,
+			Dimensions: []Dimension{{Name: metrics.MetricDimensionSLO, Value: slo.Name}},
+		},
+		Period: period,
+		Stat:   "Sum",
+	}
+}
+
+// burnRateExpression divides the error rate of the good and total queries by the error budget.
+func burnRateExpression(slo metrics.SLO) string {
+	return fmt.Sprintf("IF(total > 0, (total - good) / total / %s, 0)", formatBudget(slo))
+}
+
+// budgetConsumedExpression returns the percentage of the error budget spent by the bad requests of the
+// good and total queries.
+func budgetConsumedExpression(slo metrics.SLO) string {
+	return fmt.Sprintf("100 * (total - good) / total / %s", formatBudget(slo))
+}
+
+func formatBudget(slo metrics.SLO) string {
+	return strconv.FormatFloat(slo.ErrorBudget(), 'g', 6, 64)
+}
+
+// SLODashboard returns a dashboard with a row per SLO: its SLI against the objective, the burn rate
+// against the alarm thresholds, and the error budget consumed over the window.
+func SLODashboard(name string, slos []metrics.SLO, opts Options) (Dashboard, error) {
+	return newDashboard(name, sloWidgets(slos, opts, 0))
+}
+
+// sloWidgets returns the SLO dashboard rows, starting at row y.
+func sloWidgets(slos []metrics.SLO, opts Options, y int) []widget {
+	var widgets []widget
+	for _, slo := range slos {
+		good := []any{opts.Namespace, metrics.MetricNameSLOGoodCount, metrics.MetricDimensionSLO, slo.Name, map[string]any{"id": "good", "stat": "Sum", "visible": false}}
+		total := []any{opts.Namespace, metrics.MetricNameSLOTotalCount, metrics.MetricDimensionSLO, slo.Name, map[string]any{"id": "total", "stat": "Sum", "visible": false}}
+
+		var thresholds []annotation
+		for _, w := range burnRateWindows {
+			thresholds = append(thresholds, annotation{Label: w.name, Value: roundThreshold(w.threshold(slo))})
+		}
+
+		widgets = append(widgets,
+			widget{Type: "metric", X: 0, Y: y, Width: 8, Height: rowHeight, Properties: widgetProperties{
+				Title:  fmt.Sprintf("%s: SLI (%s)", slo.Name, describeObjective(slo)),
+				Region: opts.Region,
+				View:   "timeSeries",
+				Period: 300,
+				Metrics: [][]any{
+					{map[string]any{"id": "sli", "expression": "100 * good / total", "label": "Good requests (%)"}},
+					good,
+					total,
+				},
+				Annotations: &annotations{Horizontal: []annotation{{Label: "Objective", Value: slo.Objective}}},
+			}},
+			widget{Type: "metric", X: 8, Y: y, Width: 8, Height: rowHeight, Properties: widgetProperties{
+				Title:  slo.Name + ": burn rate (1h)",
+				Region: opts.Region,
+				View:   "timeSeries",
+				Period: 3600,
+				Metrics: [][]any{
+					{map[string]any{"id": "burn_rate", "expression": burnRateExpression(slo), "label": "Burn rate"}},
+					good,
+					total,
+				},
+				Annotations: &annotations{Horizontal: thresholds},
+			}},
+			widget{Type: "metric", X: 16, Y: y, Width: 8, Height: rowHeight, Properties: widgetProperties{
+				Title:                fmt.Sprintf("%s: error budget consumed (%d days)", slo.Name, slo.WindowDays),
+				Region:               opts.Region,
+				View:                 "singleValue",
+				Stat:                 "Sum",
+				Period:               86400,
+				Start:                fmt.Sprintf("-P%dD", slo.WindowDays),
+				SetPeriodToTimeRange: true,
+				// With the period set to the time range, good and total are the sums over the whole window.
+				Metrics: [][]any{
+					{map[string]any{"id": "consumed", "expression": budgetConsumedExpression(slo), "label": "Error budget consumed (%)"}},
+					good,
+					total,
+				},
+			}},
+		)
+		y += rowHeight
+	}
+	return widgets
+}
+
+// describeObjective describes an SLO objective such as "99.9% within 300ms".
+func describeObjective(slo metrics.SLO) string {
+	objective := strconv.FormatFloat(slo.Objective, 'f', -1, 64) + "%"
+	if slo.Latency != "" {
+		return objective + " within " + slo.Latency
+	}
+	return objective + " available"
+}
+
+// formatWindow formats a window as 5m or 6h.
+func formatWindow(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return strconv.Itoa(int(d.Hours())) + "h"
+	}
+	return strconv.Itoa(int(d.Minutes())) + "m"
+}
+
+// roundThreshold rounds away binary noise such as 14.399999999999999.
+func roundThreshold(v float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 6, 64), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
@@ -0,0 +1,110 @@
+package cwgen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+func testSLOs(t *testing.T) []metrics.SLO {
+	t.Helper()
+
+	slos, err := metrics.ParseSLOs(`[
+		{"name":"users-availability","path":"/users/:id","objective":99.9},
+		{"name":"checkout-latency","path":"/checkout","objective":99,"latency":"300ms","window_days":28}
+	]`)
+	require.NoError(t, err)
+	return slos
+}
+
+func TestSLOAlarms(t *testing.T) {
+	alarms := SLOAlarms(testSLOs(t), Options{Namespace: "ALBAccessLog", AlarmPrefix: "prod-", AlarmActions: []string{"arn:aws:sns:us-east-1:123456789012:oncall"}})
+
+	require.Len(t, alarms.MetricAlarms, 8)
+	require.Len(t, alarms.CompositeAlarms, 4)
+
+	thresholds := make(map[string]float64)
+	for _, alarm := range alarms.MetricAlarms {
+		thresholds[alarm.AlarmName] = alarm.Threshold
+		assert.Empty(t, alarm.AlarmActions, "metric alarms only feed the composite alarms")
+	}
+	assert.Equal(t, map[string]float64{
+		"prod-users-availability-burn-rate-1h":  14.4,
+		"prod-users-availability-burn-rate-5m":  14.4,
+		"prod-users-availability-burn-rate-6h":  6,
+		"prod-users-availability-burn-rate-30m": 6,
+		"prod-checkout-latency-burn-rate-1h":    13.44,
+		"prod-checkout-latency-burn-rate-5m":    13.44,
+		"prod-checkout-latency-burn-rate-6h":    5.6,
+		"prod-checkout-latency-burn-rate-30m":   5.6,
+	}, thresholds)
+
+	alarm := alarms.MetricAlarms[0]
+	assert.Equal(t, []MetricDataQuery{
+		{ID: "good", MetricStat: &MetricStat{
+			Metric: Metric{Namespace: "ALBAccessLog", MetricName: "SLOGoodCount", Dimensions: []Dimension{{Name: "SLO", Value: "users-availability"}}},
+			Period: 3600,
+			Stat:   "Sum",
+		}},
+		{ID: "total", MetricStat: &MetricStat{
+			Metric: Metric{Namespace: "ALBAccessLog", MetricName: "SLOTotalCount", Dimensions: []Dimension{{Name: "SLO", Value: "users-availability"}}},
+			Period: 3600,
+			Stat:   "Sum",
+		}},
+		{ID: "burn_rate", Expression: "IF(total > 0, (total - good) / total / 0.001, 0)", Label: "Burn rate", ReturnData: true},
+	}, alarm.Metrics)
+
+	composite := alarms.CompositeAlarms[0]
+	assert.Equal(t, "prod-users-availability-fast-burn", composite.AlarmName)
+	assert.Equal(t, `ALARM("prod-users-availability-burn-rate-1h") AND ALARM("prod-users-availability-burn-rate-5m")`, composite.AlarmRule)
+	assert.Equal(t, []string{"arn:aws:sns:us-east-1:123456789012:oncall"}, composite.AlarmActions)
+	assert.Contains(t, composite.AlarmDescription, "99.9% available")
+
+	b, err := json.Marshal(alarm)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"ReturnData":false`)
+	assert.NotContains(t, string(b), `"AlarmActions"`)
+}
+
+func TestSLODashboard(t *testing.T) {
+	dashboard, err := SLODashboard("slos", testSLOs(t), Options{Namespace: "ALBAccessLog", Region: "ap-northeast-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "slos", dashboard.DashboardName)
+
+	var body struct {
+		Widgets []struct {
+			Y          int `json:"y"`
+			Properties struct {
+				Title  string  `json:"title"`
+				Region string  `json:"region"`
+				Start  string  `json:"start"`
+				Metric [][]any `json:"metrics"`
+			} `json:"properties"`
+		} `json:"widgets"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(dashboard.DashboardBody), &body))
+	require.Len(t, body.Widgets, 6)
+
+	var titles []string
+	for _, w := range body.Widgets {
+		titles = append(titles, w.Properties.Title)
+		assert.Equal(t, "ap-northeast-1", w.Properties.Region)
+	}
+	assert.Equal(t, []string{
+		"users-availability: SLI (99.9% available)",
+		"users-availability: burn rate (1h)",
+		"users-availability: error budget consumed (30 days)",
+		"checkout-latency: SLI (99% within 300ms)",
+		"checkout-latency: burn rate (1h)",
+		"checkout-latency: error budget consumed (28 days)",
+	}, titles)
+
+	assert.Equal(t, 6, body.Widgets[3].Y)
+	assert.Equal(t, "-P28D", body.Widgets[5].Properties.Start)
+	assert.Equal(t, []any{map[string]any{"id": "consumed", "expression": "100 * (total - good) / total / 0.01", "label": "Error budget consumed (%)"}}, body.Widgets[5].Properties.Metric[0])
+	assert.Equal(t, []any{"ALBAccessLog", "SLOTotalCount", "SLO", "checkout-latency", map[string]any{"id": "total", "stat": "Sum", "visible": false}}, body.Widgets[5].Properties.Metric[2])
+}
@@ -0,0 +1,276 @@
+package cwgen
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CloudFormationTemplate is a CloudFormation template in its JSON form.
+type CloudFormationTemplate struct {
+	AWSTemplateFormatVersion string                             `json:"AWSTemplateFormatVersion"`
+	Description              string                             `json:"Description,omitempty"`
+	Parameters               map[string]CloudFormationParameter `json:"Parameters,omitempty"`
+	Conditions               map[string]any                     `json:"Conditions,omitempty"`
+	Resources                map[string]CloudFormationResource  `json:"Resources"`
+	Outputs                  map[string]CloudFormationOutput    `json:"Outputs,omitempty"`
+}
+
+// CloudFormationParameter is a parameter of a CloudFormation template. A parameter without a Default is
+// required, so an empty string default is kept.
+type CloudFormationParameter struct {
+	Type          string   `json:"Type"`
+	Description   string   `json:"Description,omitempty"`
+	Default       any      `json:"Default,omitempty"`
+	AllowedValues []string `json:"AllowedValues,omitempty"`
+	MinValue      *int     `json:"MinValue,omitempty"`
+}
+
+// CloudFormationResource is a resource of a CloudFormation template.
+type CloudFormationResource struct {
+	Type       string   `json:"Type"`
+	Condition  string   `json:"Condition,omitempty"`
+	DependsOn  []string `json:"DependsOn,omitempty"`
+	Properties any      `json:"Properties"`
+}
+
+// CloudFormationOutput is an output of a CloudFormation template.
+type CloudFormationOutput struct {
+	Description string `json:"Description,omitempty"`
+	Value       any    `json:"Value"`
+}
+
+// TerraformConfig is a Terraform configuration in its JSON syntax, as written to a .tf.json file.
+type TerraformConfig struct {
+	Terraform map[string]any               `json:"terraform,omitempty"`
+	Variable  map[string]TerraformVariable `json:"variable,omitempty"`
+	Locals    map[string]any               `json:"locals,omitempty"`
+	// Data and Resource map a data source or resource type and name to its arguments.
+	Data     map[string]map[string]any  `json:"data,omitempty"`
+	Resource map[string]map[string]any  `json:"resource"`
+	Output   map[string]TerraformOutput `json:"output,omitempty"`
+}
+
+// TerraformVariable is an input variable of a Terraform module. A variable without a default is
+// required, so an empty string default is kept.
+type TerraformVariable struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Default     any    `json:"default,omitempty"`
+}
+
+// TerraformOutput is an output value of a Terraform module.
+type TerraformOutput struct {
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value"`
+}
+
+type terraformDashboard struct {
+	DashboardName string `json:"dashboard_name"`
+	DashboardBody string `json:"dashboard_body"`
+}
+
+type terraformMetricAlarm struct {
+	AlarmName          string                 `json:"alarm_name"`
+	AlarmDescription   string                 `json:"alarm_description,omitempty"`
+	AlarmActions       []string               `json:"alarm_actions,omitempty"`
+	ComparisonOperator string                 `json:"comparison_operator"`
+	EvaluationPeriods  int                    `json:"evaluation_periods"`
+	Threshold          float64                `json:"threshold"`
+	TreatMissingData   string                 `json:"treat_missing_data"`
+	MetricQuery        []terraformMetricQuery `json:"metric_query"`
+}
+
+type terraformMetricQuery struct {
+	ID         string           `json:"id"`
+	Expression string           `json:"expression,omitempty"`
+	Label      string           `json:"label,omitempty"`
+	ReturnData bool             `json:"return_data"`
+	Metric     *terraformMetric `json:"metric,omitempty"`
+}
+
+type terraformMetric struct {
+	MetricName string            `json:"metric_name"`
+	Namespace  string            `json:"namespace"`
+	Period     int               `json:"period"`
+	Stat       string            `json:"stat"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+}
+
+type terraformCompositeAlarm struct {
+	AlarmName        string   `json:"alarm_name"`
+	AlarmDescription string   `json:"alarm_description,omitempty"`
+	AlarmRule        string   `json:"alarm_rule"`
+	AlarmActions     []string `json:"alarm_actions,omitempty"`
+	DependsOn        []string `json:"depends_on,omitempty"`
+}
+
+// alarmRuleRef matches the alarms an alarm rule refers to.
+var alarmRuleRef = regexp.MustCompile(`ALARM\("((?:[^"\\]|\\.)*)"\)`)
+
+// CloudFormation returns the resources as a CloudFormation template. The API shapes are also the
+// CloudFormation property names, so the resources are used as their properties.
+func (r Resources) CloudFormation() CloudFormationTemplate {
+	t := CloudFormationTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Description:              "CloudWatch dashboard and alarms for ALB path metrics",
+		Resources: map[string]CloudFormationResource{
+			"Dashboard": {Type: "AWS::CloudWatch::Dashboard", Properties: r.Dashboard},
+		},
+	}
+
+	ids := newNames(logicalID)
+	alarmIDs := make(map[string]string, len(r.MetricAlarms))
+	for _, alarm := range r.MetricAlarms {
+		id := ids.add("Alarm " + alarm.AlarmName)
+		alarmIDs[alarm.AlarmName] = id
+		t.Resources[id] = CloudFormationResource{Type: "AWS::CloudWatch::Alarm", Properties: alarm}
+	}
+	for _, alarm := range r.CompositeAlarms {
+		t.Resources[ids.add("Alarm "+alarm.AlarmName)] = CloudFormationResource{
+			Type:       "AWS::CloudWatch::CompositeAlarm",
+			DependsOn:  dependencies(alarm, alarmIDs, ""),
+			Properties: alarm,
+		}
+	}
+	return t
+}
+
+// Terraform returns the resources as a Terraform configuration.
+func (r Resources) Terraform() TerraformConfig {
+	dashboards := map[string]any{
+		terraformName(r.Dashboard.DashboardName): terraformDashboard{DashboardName: r.Dashboard.DashboardName, DashboardBody: r.Dashboard.DashboardBody},
+	}
+
+	names := newNames(terraformName)
+	alarmNames := make(map[string]string, len(r.MetricAlarms))
+	metricAlarms := make(map[string]any, len(r.MetricAlarms))
+	for _, alarm := range r.MetricAlarms {
+		name := names.add(alarm.AlarmName)
+		alarmNames[alarm.AlarmName] = name
+		metricAlarms[name] = newTerraformMetricAlarm(alarm)
+	}
+
+	compositeAlarms := make(map[string]any, len(r.CompositeAlarms))
+	for _, alarm := range r.CompositeAlarms {
+		compositeAlarms[names.add(alarm.AlarmName)] = terraformCompositeAlarm{
+			AlarmName:        alarm.AlarmName,
+			AlarmDescription: alarm.AlarmDescription,
+			AlarmRule:        alarm.AlarmRule,
+			AlarmActions:     alarm.AlarmActions,
+			DependsOn:        dependencies(alarm, alarmNames, "aws_cloudwatch_metric_alarm."),
+		}
+	}
+
+	c := TerraformConfig{Resource: map[string]map[string]any{"aws_cloudwatch_dashboard": dashboards}}
+	if len(metricAlarms) > 0 {
+		c.Resource["aws_cloudwatch_metric_alarm"] = metricAlarms
+	}
+	if len(compositeAlarms) > 0 {
+		c.Resource["aws_cloudwatch_composite_alarm"] = compositeAlarms
+	}
+	return c
+}
+
+func newTerraformMetricAlarm(alarm MetricAlarm) terraformMetricAlarm {
+	queries := make([]terraformMetricQuery, 0, len(alarm.Metrics))
+	for _, m := range alarm.Metrics {
+		q := terraformMetricQuery{ID: m.ID, Expression: m.Expression, Label: m.Label, ReturnData: m.ReturnData}
+		if m.MetricStat != nil {
+			dimensions := make(map[string]string, len(m.MetricStat.Metric.Dimensions))
+			for _, d := range m.MetricStat.Metric.Dimensions {
+				dimensions[d.Name] = d.Value
+			}
+			q.Metric = &terraformMetric{
+				MetricName: m.MetricStat.Metric.MetricName,
+				Namespace:  m.MetricStat.Metric.Namespace,
+				Period:     m.MetricStat.Period,
+				Stat:       m.MetricStat.Stat,
+				Dimensions: dimensions,
+			}
+		}
+		queries = append(queries, q)
+	}
+
+	return terraformMetricAlarm{
+		AlarmName:          alarm.AlarmName,
+		AlarmDescription:   alarm.AlarmDescription,
+		AlarmActions:       alarm.AlarmActions,
+		ComparisonOperator: alarm.ComparisonOperator,
+		EvaluationPeriods:  alarm.EvaluationPeriods,
+		Threshold:          alarm.Threshold,
+		TreatMissingData:   alarm.TreatMissingData,
+		MetricQuery:        queries,
+	}
+}
+
+// dependencies returns the resources of the alarms a composite alarm refers to. Composite alarms refer to
+// alarms by name, so the dependency is not otherwise visible to CloudFormation or Terraform.
+func dependencies(alarm CompositeAlarm, resources map[string]string, prefix string) []string {
+	var deps []string
+	for _, match := range alarmRuleRef.FindAllStringSubmatch(alarm.AlarmRule, -1) {
+		name, err := strconv.Unquote(`"` + match[1] + `"`)
+		if err != nil {
+			continue
+		}
+		if resource, ok := resources[name]; ok {
+			deps = append(deps, prefix+resource)
+		}
+	}
+	return deps
+}
+
+// names hands out unique resource names, numbering names that collide after sanitizing.
+type names struct {
+	sanitize func(string) string
+	used     map[string]bool
+}
+
+func newNames(sanitize func(string) string) *names {
+	return &names{sanitize: sanitize, used: make(map[string]bool)}
+}
+
+func (n *names) add(s string) string {
+	name := n.sanitize(s)
+	unique := name
+	for i := 2; n.used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	n.used[unique] = true
+	return unique
+}
+
+// logicalID turns a name such as "Alarm prod-checkout-fast-burn" into a CloudFormation logical ID such
+// as AlarmProdCheckoutFastBurn.
+func logicalID(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// terraformName turns a name into a Terraform resource name, which must start with a letter or an
+// underscore and contain only letters, digits, underscores and dashes.
+func terraformName(s string) string {
+	name := []rune(s)
+	for i, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || !(unicode.IsLetter(name[0]) || name[0] == '_') {
+		return "_" + string(name)
+	}
+	return string(name)
+}
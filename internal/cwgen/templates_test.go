@@ -0,0 +1,97 @@
+package cwgen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResources(t *testing.T) Resources {
+	t.Helper()
+
+	resources, err := Generate("alb-paths", testPaths(t), testSLOs(t), Options{
+		Namespace:    "ALBAccessLog",
+		Region:       "us-east-1",
+		AlarmPrefix:  "prod-",
+		AlarmActions: []string{"arn:aws:sns:us-east-1:123456789012:oncall"},
+	})
+	require.NoError(t, err)
+	return resources
+}
+
+func TestResources_CloudFormation(t *testing.T) {
+	resources := testResources(t)
+	template := resources.CloudFormation()
+
+	assert.Equal(t, "2010-09-09", template.AWSTemplateFormatVersion)
+	require.Len(t, template.Resources, 1+8+4)
+
+	dashboard := template.Resources["Dashboard"]
+	assert.Equal(t, "AWS::CloudWatch::Dashboard", dashboard.Type)
+	assert.Equal(t, resources.Dashboard, dashboard.Properties)
+
+	alarm := template.Resources["AlarmProdUsersAvailabilityBurnRate1h"]
+	assert.Equal(t, "AWS::CloudWatch::Alarm", alarm.Type)
+	assert.Equal(t, resources.MetricAlarms[0], alarm.Properties)
+
+	composite := template.Resources["AlarmProdUsersAvailabilityFastBurn"]
+	assert.Equal(t, "AWS::CloudWatch::CompositeAlarm", composite.Type)
+	assert.Equal(t, []string{"AlarmProdUsersAvailabilityBurnRate1h", "AlarmProdUsersAvailabilityBurnRate5m"}, composite.DependsOn)
+
+	b, err := json.Marshal(template)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"AlarmRule":"ALARM(\"prod-users-availability-burn-rate-1h\") AND ALARM(\"prod-users-availability-burn-rate-5m\")"`)
+}
+
+func TestResources_Terraform(t *testing.T) {
+	resources := testResources(t)
+	config := resources.Terraform()
+
+	require.Len(t, config.Resource["aws_cloudwatch_dashboard"], 1)
+	assert.Equal(t, terraformDashboard{DashboardName: "alb-paths", DashboardBody: resources.Dashboard.DashboardBody}, config.Resource["aws_cloudwatch_dashboard"]["alb-paths"])
+
+	require.Len(t, config.Resource["aws_cloudwatch_metric_alarm"], 8)
+	alarm := config.Resource["aws_cloudwatch_metric_alarm"]["prod-users-availability-burn-rate-1h"].(terraformMetricAlarm)
+	assert.Equal(t, 14.4, alarm.Threshold)
+	assert.Empty(t, alarm.AlarmActions)
+	assert.Equal(t, []terraformMetricQuery{
+		{ID: "good", Metric: &terraformMetric{MetricName: "SLOGoodCount", Namespace: "ALBAccessLog", Period: 3600, Stat: "Sum", Dimensions: map[string]string{"SLO": "users-availability"}}},
+		{ID: "total", Metric: &terraformMetric{MetricName: "SLOTotalCount", Namespace: "ALBAccessLog", Period: 3600, Stat: "Sum", Dimensions: map[string]string{"SLO": "users-availability"}}},
+		{ID: "burn_rate", Expression: "IF(total > 0, (total - good) / total / 0.001, 0)", Label: "Burn rate", ReturnData: true},
+	}, alarm.MetricQuery)
+
+	require.Len(t, config.Resource["aws_cloudwatch_composite_alarm"], 4)
+	composite := config.Resource["aws_cloudwatch_composite_alarm"]["prod-checkout-latency-slow-burn"].(terraformCompositeAlarm)
+	assert.Equal(t, []string{"arn:aws:sns:us-east-1:123456789012:oncall"}, composite.AlarmActions)
+	assert.Equal(t, []string{
+		"aws_cloudwatch_metric_alarm.prod-checkout-latency-burn-rate-6h",
+		"aws_cloudwatch_metric_alarm.prod-checkout-latency-burn-rate-30m",
+	}, composite.DependsOn)
+
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"return_data":false`)
+}
+
+func TestResources_TerraformWithoutAlarms(t *testing.T) {
+	resources, err := Generate("alb-paths", testPaths(t), nil, Options{Namespace: "ALBAccessLog", Region: "us-east-1"})
+	require.NoError(t, err)
+
+	config := resources.Terraform()
+	assert.Len(t, config.Resource, 1)
+	assert.Contains(t, config.Resource, "aws_cloudwatch_dashboard")
+}
+
+func TestResourceNames(t *testing.T) {
+	assert.Equal(t, "AlarmProdCheckoutFastBurn", logicalID("Alarm prod-checkout-fast-burn"))
+	assert.Equal(t, "AlarmCafUsers", logicalID("Alarm café.users"))
+
+	assert.Equal(t, "prod-checkout_v2", terraformName("prod-checkout.v2"))
+	assert.Equal(t, "_5xx-alarm", terraformName("5xx-alarm"))
+
+	n := newNames(logicalID)
+	assert.Equal(t, "AlarmAB", n.add("alarm a-b"))
+	assert.Equal(t, "AlarmAB2", n.add("alarm a.b"))
+}
@@ -0,0 +1,152 @@
+package infra
+
+import (
+	"strconv"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/cwgen"
+)
+
+// object is a JSON object of a template.
+type object = map[string]any
+
+func ref(name string) object {
+	return object{"Ref": name}
+}
+
+func getAtt(resource, attribute string) object {
+	return object{"Fn::GetAtt": []string{resource, attribute}}
+}
+
+func sub(s string) object {
+	return object{"Fn::Sub": s}
+}
+
+// CloudFormation returns the template that deploys the function from the deployment package at
+// packagePath, which aws cloudformation package uploads. CloudFormation cannot add a notification to a
+// bucket outside the stack, so the template allows S3 to invoke the function and outputs the notification
+// configuration to put on the bucket.
+func CloudFormation(packagePath string) cwgen.CloudFormationTemplate {
+	one := 1
+	parameters := map[string]cwgen.CloudFormationParameter{
+		"LogBucketName":      {Type: "String", Description: "Bucket the ALB writes its access logs to"},
+		"FunctionName":       {Type: "String", Description: "Name of the function and its dead-letter queue", Default: defaultFunctionName},
+		"Timeout":            {Type: "Number", Description: "Function timeout in seconds", Default: strconv.Itoa(defaultTimeout), MinValue: &one},
+		"MemorySize":         {Type: "Number", Description: "Function memory in MB", Default: strconv.Itoa(defaultMemorySize), MinValue: &one},
+		"LogRetentionInDays": {Type: "Number", Description: "Retention of the function's log group", Default: strconv.Itoa(defaultLogRetention)},
+		"Layers":             {Type: "CommaDelimitedList", Description: "ARNs of layers to attach, such as one with the CLIENT_NETWORKS databases under /opt", Default: ""},
+	}
+
+	variables := object{}
+	for _, s := range settings {
+		p := cwgen.CloudFormationParameter{Type: "String", Description: s.description + " (" + s.env + ")"}
+		if !s.required {
+			p.Default = ""
+		}
+		parameters[parameterName(s.env)] = p
+		variables[s.env] = ref(parameterName(s.env))
+	}
+
+	logKeyPrefix := parameterName("LOG_KEY_PREFIX")
+	pipelineNamespace := parameterName("PIPELINE_METRICS_NAMESPACE")
+
+	return cwgen.CloudFormationTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Description:              "Publishes CloudWatch metrics per ALB path from ALB access logs",
+		Parameters:               parameters,
+		Conditions: object{
+			"HasLayers":            object{"Fn::Not": []any{object{"Fn::Equals": []any{object{"Fn::Join": []any{"", ref("Layers")}}, ""}}}},
+			"HasLogKeyPrefix":      object{"Fn::Not": []any{object{"Fn::Equals": []any{ref(logKeyPrefix), ""}}}},
+			"HasPipelineNamespace": object{"Fn::Not": []any{object{"Fn::Equals": []any{ref(pipelineNamespace), ""}}}},
+		},
+		Resources: map[string]cwgen.CloudFormationResource{
+			"LogGroup": {Type: "AWS::Logs::LogGroup", Properties: object{
+				"LogGroupName":    sub("/aws/lambda/${FunctionName}"),
+				"RetentionInDays": ref("LogRetentionInDays"),
+			}},
+			"DeadLetterQueue": {Type: "AWS::SQS::Queue", Properties: object{
+				"QueueName":              sub("${FunctionName}-dlq"),
+				"MessageRetentionPeriod": deadLetterRetention,
+				"SqsManagedSseEnabled":   true,
+			}},
+			"Role": {Type: "AWS::IAM::Role", Properties: object{
+				"AssumeRolePolicyDocument": policyDocument(object{
+					"Effect":    "Allow",
+					"Principal": object{"Service": "lambda.amazonaws.com"},
+					"Action":    "sts:AssumeRole",
+				}),
+				"Policies": []object{{
+					"PolicyName": "function",
+					"PolicyDocument": policyDocument(
+						object{
+							"Sid":      "ReadAlbLogs",
+							"Effect":   "Allow",
+							"Action":   "s3:GetObject",
+							"Resource": sub("arn:${AWS::Partition}:s3:::${LogBucketName}/${" + logKeyPrefix + "}*"),
+						},
+						object{
+							"Sid":      "PublishMetrics",
+							"Effect":   "Allow",
+							"Action":   "cloudwatch:PutMetricData",
+							"Resource": "*",
+							"Condition": object{"StringEquals": object{"cloudwatch:namespace": object{"Fn::If": []any{
+								"HasPipelineNamespace",
+								[]any{namespace, ref(pipelineNamespace)},
+								[]any{namespace},
+							}}}},
+						},
+						object{
+							"Sid":      "WriteLogs",
+							"Effect":   "Allow",
+							"Action":   []string{"logs:CreateLogStream", "logs:PutLogEvents"},
+							"Resource": getAtt("LogGroup", "Arn"),
+						},
+						object{
+							"Sid":      "SendToDeadLetterQueue",
+							"Effect":   "Allow",
+							"Action":   "sqs:SendMessage",
+							"Resource": getAtt("DeadLetterQueue", "Arn"),
+						},
+					),
+				}},
+			}},
+			"Function": {Type: "AWS::Lambda::Function", DependsOn: []string{"LogGroup"}, Properties: object{
+				"FunctionName":     ref("FunctionName"),
+				"Description":      "Publishes CloudWatch metrics per ALB path from ALB access logs",
+				"Runtime":          "provided.al2023",
+				"Handler":          "bootstrap",
+				"Architectures":    []string{"arm64"},
+				"Code":             packagePath,
+				"Layers":           object{"Fn::If": []any{"HasLayers", ref("Layers"), ref("AWS::NoValue")}},
+				"Role":             getAtt("Role", "Arn"),
+				"Timeout":          ref("Timeout"),
+				"MemorySize":       ref("MemorySize"),
+				"DeadLetterConfig": object{"TargetArn": getAtt("DeadLetterQueue", "Arn")},
+				"Environment":      object{"Variables": variables},
+			}},
+			"InvokePermission": {Type: "AWS::Lambda::Permission", Properties: object{
+				"Action":        "lambda:InvokeFunction",
+				"FunctionName":  getAtt("Function", "Arn"),
+				"Principal":     "s3.amazonaws.com",
+				"SourceArn":     sub("arn:${AWS::Partition}:s3:::${LogBucketName}"),
+				"SourceAccount": ref("AWS::AccountId"),
+			}},
+		},
+		Outputs: map[string]cwgen.CloudFormationOutput{
+			"FunctionArn":        {Description: "ARN of the function", Value: getAtt("Function", "Arn")},
+			"DeadLetterQueueUrl": {Description: "Queue that receives events the function failed to process", Value: ref("DeadLetterQueue")},
+			"NotificationConfiguration": {
+				Description: "Notification configuration for aws s3api put-bucket-notification-configuration on the log bucket",
+				Value: object{"Fn::If": []any{
+					"HasLogKeyPrefix",
+					sub(`{"LambdaFunctionConfigurations":[{"LambdaFunctionArn":"${Function.Arn}","Events":["s3:ObjectCreated:*"],` +
+						`"Filter":{"Key":{"FilterRules":[{"Name":"prefix","Value":"${` + logKeyPrefix + `}"}]}}}]}`),
+					sub(`{"LambdaFunctionConfigurations":[{"LambdaFunctionArn":"${Function.Arn}","Events":["s3:ObjectCreated:*"]}]}`),
+				}},
+			},
+		},
+	}
+}
+
+func policyDocument(statements ...object) object {
+	return object{"Version": "2012-10-17", "Statement": statements}
+}
@@ -0,0 +1,97 @@
+package infra
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudFormation(t *testing.T) {
+	template := CloudFormation("function.zip")
+
+	function := template.Resources["Function"].Properties.(object)
+	assert.Equal(t, "function.zip", function["Code"])
+	assert.Equal(t, []string{"arm64"}, function["Architectures"])
+	assert.Equal(t, "provided.al2023", function["Runtime"])
+	assert.Equal(t, object{"TargetArn": getAtt("DeadLetterQueue", "Arn")}, function["DeadLetterConfig"])
+	assert.Equal(t, object{"Fn::If": []any{"HasLayers", ref("Layers"), ref("AWS::NoValue")}}, function["Layers"])
+	assert.Equal(t, "CommaDelimitedList", template.Parameters["Layers"].Type)
+
+	variables := function["Environment"].(object)["Variables"].(object)
+	assert.Len(t, variables, len(settings))
+	assert.Equal(t, ref("IncludePathRules"), variables["INCLUDE_PATH_RULES"])
+
+	assert.Nil(t, template.Parameters["IncludePathRules"].Default, "the rules are required")
+	assert.Nil(t, template.Parameters["LogBucketName"].Default, "the bucket is required")
+	assert.Equal(t, "", template.Parameters["DryRun"].Default)
+
+	b, err := json.Marshal(template.Resources["Role"])
+	require.NoError(t, err)
+	policy := string(b)
+	assert.Contains(t, policy, `"Resource":{"Fn::Sub":"arn:${AWS::Partition}:s3:::${LogBucketName}/${LogKeyPrefix}*"},"Sid":"ReadAlbLogs"`)
+	assert.Contains(t, policy, `"cloudwatch:namespace":{"Fn::If":["HasPipelineNamespace",["ALBAccessLog",{"Ref":"PipelineMetricsNamespace"}],["ALBAccessLog"]]}`)
+	assert.NotContains(t, policy, `"Action":"*"`)
+	assert.NotContains(t, policy, "ManagedPolicyArns")
+}
+
+// TestCloudFormation_References checks that every reference in the template resolves, since the template
+// cannot be validated against CloudFormation in tests.
+func TestCloudFormation_References(t *testing.T) {
+	template := CloudFormation(DefaultPackage)
+
+	names := map[string]bool{"AWS::AccountId": true, "AWS::NoValue": true, "AWS::Partition": true, "AWS::Region": true}
+	for name := range template.Parameters {
+		names[name] = true
+	}
+	resources := make(map[string]bool)
+	for name, resource := range template.Resources {
+		names[name] = true
+		resources[name] = true
+		for _, dep := range resource.DependsOn {
+			assert.Contains(t, template.Resources, dep, "%s depends on an unknown resource", name)
+		}
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				switch key {
+				case "Ref":
+					assert.True(t, names[value.(string)], "Ref to unknown %v", value)
+				case "Fn::GetAtt":
+					assert.True(t, resources[value.([]any)[0].(string)], "GetAtt of unknown %v", value)
+				case "Fn::If":
+					assert.Contains(t, template.Conditions, value.([]any)[0], "unknown condition")
+				case "Fn::Sub":
+					for _, m := range regexp.MustCompile(`\$\{([^}]+)\}`).FindAllStringSubmatch(value.(string), -1) {
+						name, _, _ := strings.Cut(m[1], ".")
+						if strings.HasPrefix(m[1], "AWS::") {
+							name = m[1]
+						}
+						assert.True(t, names[name], "Sub of unknown %s", m[1])
+					}
+				}
+				walk(value)
+			}
+		case []any:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+
+	// Walk the encoded form so that typed values and the template's own structs look alike.
+	b, err := json.Marshal(template)
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	walk(decoded["Resources"])
+	walk(decoded["Outputs"])
+	walk(decoded["Conditions"])
+}
@@ -0,0 +1,91 @@
+// Package infra renders the CloudFormation template and Terraform module that deploy the function: its
+// least-privilege role, the arm64 Lambda function, the S3 notification, a dead-letter queue and a
+// parameter per setting.
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+const (
+	// DefaultPackage is the deployment package built by make, relative to the deploy directory.
+	DefaultPackage = "../dist/cloudwatch-alb-path-metrics.zip"
+
+	defaultFunctionName = "cloudwatch-alb-path-metrics"
+	defaultTimeout      = 300
+	defaultMemorySize   = 512
+	defaultLogRetention = 30
+
+	// deadLetterRetention keeps failed events for the 14-day maximum.
+	deadLetterRetention = 14 * 24 * 60 * 60
+
+	// namespace is the namespace the function publishes to; the role may publish to it and to the
+	// pipeline namespace alone.
+	namespace = metrics.Namespace
+)
+
+// setting is a function setting, deployed as an environment variable from a template parameter.
+type setting struct {
+	env         string
+	description string
+	// required settings have no default, so the template cannot be deployed without them.
+	required bool
+}
+
+// settings are the environment variables the function reads, except those meant for local runs.
+var settings = []setting{
+	{env: "INCLUDE_PATH_RULES", description: "JSON path rules", required: true},
+	{env: "PATH_METRICS", description: "JSON metric selection for rules without their own"},
+	{env: "SLOS", description: "JSON service level objectives on rule names"},
+	{env: "CLIENT_TYPES", description: "JSON user agent classification for the ClientType dimension"},
+	{env: "CLIENT_NETWORKS", description: "JSON Country, ASN and Network breakdowns; attach the layer holding the databases with the layers setting"},
+	{env: "LOG_KEY_PREFIX", description: "Only read objects whose key starts with this prefix; also scopes the role and the notification"},
+	{env: "PIPELINE_METRICS_NAMESPACE", description: "Namespace of the pipeline metrics; empty disables them"},
+	{env: "DRY_RUN", description: "true logs the metrics instead of publishing them"},
+	{env: "COVERAGE_REPORT", description: "true logs a rule coverage report per invocation"},
+	{env: "PUBLISH_UNMATCHED_COUNT", description: "true publishes UnmatchedRequestCount per host"},
+	{env: "UNAVAILABLE_AS_FAILED", description: "true counts requests that reached no target as failed"},
+	{env: "TLS_METRICS", description: "true publishes TLSRequestCount"},
+	{env: "TLS_LEGACY_ONLY", description: "true leaves TLSv1.3 out of TLSRequestCount"},
+	{env: "PARSE_ERROR_THRESHOLD", description: "Share of unparsable lines that fails an object"},
+	{env: "PARSE_ERROR_SAMPLES", description: "Unparsable lines logged per object"},
+	{env: "MAX_LINE_BYTES", description: "Longest log line parsed"},
+	{env: "MAX_TARGETS", description: "Target values published per path"},
+	{env: "EXEMPLARS", description: "Slowest and failed requests logged per path and minute"},
+	{env: "LOG_LEVEL", description: "debug, info, warn or error"},
+}
+
+// parameterName returns the CloudFormation parameter name of an environment variable, such as
+// IncludePathRules for INCLUDE_PATH_RULES.
+func parameterName(env string) string {
+	var b strings.Builder
+	for word := range strings.SplitSeq(strings.ToLower(env), "_") {
+		if word == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// variableName returns the Terraform variable name of an environment variable, such as
+// include_path_rules for INCLUDE_PATH_RULES.
+func variableName(env string) string {
+	return strings.ToLower(env)
+}
+
+// Marshal encodes a template as indented JSON, the form the files in the deploy directory are kept in.
+func Marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
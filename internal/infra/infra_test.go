@@ -0,0 +1,55 @@
+package infra
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// localOnlySettings are read by the function but only make sense against local stand-ins.
+var localOnlySettings = map[string]bool{"S3_USE_PATH_STYLE": true}
+
+func TestSettings_CoverFunction(t *testing.T) {
+	src, err := os.ReadFile("../../cmd/cloudwatch-alb-path-metrics/main.go")
+	require.NoError(t, err)
+
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.env] = true
+	}
+
+	read := regexp.MustCompile(`(?:os\.Getenv|floatEnv|intEnv)\("([A-Z0-9_]+)"\)`).FindAllStringSubmatch(string(src), -1)
+	require.NotEmpty(t, read)
+	for _, m := range read {
+		if !localOnlySettings[m[1]] {
+			assert.True(t, known[m[1]], "%s is read by the function but has no template parameter", m[1])
+		}
+	}
+}
+
+func TestParameterName(t *testing.T) {
+	assert.Equal(t, "IncludePathRules", parameterName("INCLUDE_PATH_RULES"))
+	assert.Equal(t, "Slos", parameterName("SLOS"))
+	assert.Equal(t, "include_path_rules", variableName("INCLUDE_PATH_RULES"))
+}
+
+// TestDeployFiles checks that the templates kept in deploy/ are rendered from this package.
+func TestDeployFiles(t *testing.T) {
+	files := map[string]any{
+		"../../deploy/cloudformation.json":    CloudFormation(DefaultPackage),
+		"../../deploy/terraform/main.tf.json": Terraform(),
+	}
+
+	for path, template := range files {
+		want, err := Marshal(template)
+		require.NoError(t, err)
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "%s is out of date; run make deploy-templates", strings.TrimPrefix(path, "../../"))
+	}
+}
@@ -0,0 +1,138 @@
+package infra
+
+import (
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/cwgen"
+)
+
+// Terraform returns the module that deploys the function from the deployment package given by the
+// package_file variable. The module owns the notification configuration of the log bucket unless
+// manage_bucket_notification is false, since a bucket has a single notification configuration.
+func Terraform() cwgen.TerraformConfig {
+	variables := map[string]cwgen.TerraformVariable{
+		"log_bucket_name":            {Type: "string", Description: "Bucket the ALB writes its access logs to"},
+		"package_file":               {Type: "string", Description: "Deployment package built by make, such as dist/cloudwatch-alb-path-metrics.zip"},
+		"function_name":              {Type: "string", Description: "Name of the function, its role and its dead-letter queue", Default: defaultFunctionName},
+		"timeout":                    {Type: "number", Description: "Function timeout in seconds", Default: defaultTimeout},
+		"memory_size":                {Type: "number", Description: "Function memory in MB", Default: defaultMemorySize},
+		"log_retention_in_days":      {Type: "number", Description: "Retention of the function's log group", Default: defaultLogRetention},
+		"layers":                     {Type: "list(string)", Description: "ARNs of layers to attach, such as one with the CLIENT_NETWORKS databases under /opt", Default: []string{}},
+		"manage_bucket_notification": {Type: "bool", Description: "Replace the notification configuration of the log bucket with one that invokes the function", Default: true},
+	}
+
+	environment := object{}
+	for _, s := range settings {
+		v := cwgen.TerraformVariable{Type: "string", Description: s.description + " (" + s.env + ")"}
+		if !s.required {
+			v.Default = ""
+		}
+		variables[variableName(s.env)] = v
+		environment[s.env] = "${var." + variableName(s.env) + "}"
+	}
+
+	logKeyPrefix := "var." + variableName("LOG_KEY_PREFIX")
+	pipelineNamespace := "var." + variableName("PIPELINE_METRICS_NAMESPACE")
+
+	return cwgen.TerraformConfig{
+		Terraform: object{
+			"required_providers": object{"aws": object{"source": "hashicorp/aws", "version": ">= 5.0"}},
+		},
+		Variable: variables,
+		Locals: object{
+			"bucket_arn": "arn:${data.aws_partition.current.partition}:s3:::${var.log_bucket_name}",
+			// compact drops the pipeline namespace when it is empty.
+			"namespaces": `${compact(["` + namespace + `", ` + pipelineNamespace + `])}`,
+		},
+		Data: map[string]map[string]any{
+			"aws_partition":       {"current": object{}},
+			"aws_caller_identity": {"current": object{}},
+			"aws_iam_policy_document": {
+				"assume_role": object{"statement": []object{{
+					"actions":    []string{"sts:AssumeRole"},
+					"principals": []object{{"type": "Service", "identifiers": []string{"lambda.amazonaws.com"}}},
+				}}},
+				"function": object{"statement": []object{
+					{
+						"sid":       "ReadAlbLogs",
+						"actions":   []string{"s3:GetObject"},
+						"resources": []string{"${local.bucket_arn}/${" + logKeyPrefix + "}*"},
+					},
+					{
+						"sid":       "PublishMetrics",
+						"actions":   []string{"cloudwatch:PutMetricData"},
+						"resources": []string{"*"},
+						"condition": []object{{"test": "StringEquals", "variable": "cloudwatch:namespace", "values": "${local.namespaces}"}},
+					},
+					{
+						"sid":       "WriteLogs",
+						"actions":   []string{"logs:CreateLogStream", "logs:PutLogEvents"},
+						"resources": []string{"${aws_cloudwatch_log_group.function.arn}:*"},
+					},
+					{
+						"sid":       "SendToDeadLetterQueue",
+						"actions":   []string{"sqs:SendMessage"},
+						"resources": []string{"${aws_sqs_queue.dead_letter.arn}"},
+					},
+				}},
+			},
+		},
+		Resource: map[string]map[string]any{
+			"aws_cloudwatch_log_group": {"function": object{
+				"name":              "/aws/lambda/${var.function_name}",
+				"retention_in_days": "${var.log_retention_in_days}",
+			}},
+			"aws_sqs_queue": {"dead_letter": object{
+				"name":                      "${var.function_name}-dlq",
+				"message_retention_seconds": deadLetterRetention,
+				"sqs_managed_sse_enabled":   true,
+			}},
+			"aws_iam_role": {"function": object{
+				"name":               "${var.function_name}",
+				"assume_role_policy": "${data.aws_iam_policy_document.assume_role.json}",
+			}},
+			"aws_iam_role_policy": {"function": object{
+				"name":   "function",
+				"role":   "${aws_iam_role.function.id}",
+				"policy": "${data.aws_iam_policy_document.function.json}",
+			}},
+			"aws_lambda_function": {"function": object{
+				"function_name":      "${var.function_name}",
+				"description":        "Publishes CloudWatch metrics per ALB path from ALB access logs",
+				"runtime":            "provided.al2023",
+				"handler":            "bootstrap",
+				"architectures":      []string{"arm64"},
+				"layers":             "${var.layers}",
+				"filename":           "${var.package_file}",
+				"source_code_hash":   "${filebase64sha256(var.package_file)}",
+				"role":               "${aws_iam_role.function.arn}",
+				"timeout":            "${var.timeout}",
+				"memory_size":        "${var.memory_size}",
+				"dead_letter_config": object{"target_arn": "${aws_sqs_queue.dead_letter.arn}"},
+				"environment":        object{"variables": environment},
+				"depends_on":         []string{"aws_cloudwatch_log_group.function", "aws_iam_role_policy.function"},
+			}},
+			"aws_lambda_permission": {"s3": object{
+				"statement_id":   "AllowS3Invoke",
+				"action":         "lambda:InvokeFunction",
+				"function_name":  "${aws_lambda_function.function.function_name}",
+				"principal":      "s3.amazonaws.com",
+				"source_arn":     "${local.bucket_arn}",
+				"source_account": "${data.aws_caller_identity.current.account_id}",
+			}},
+			"aws_s3_bucket_notification": {"logs": object{
+				"count":  "${var.manage_bucket_notification ? 1 : 0}",
+				"bucket": "${var.log_bucket_name}",
+				"lambda_function": []object{{
+					"lambda_function_arn": "${aws_lambda_function.function.arn}",
+					"events":              []string{"s3:ObjectCreated:*"},
+					"filter_prefix":       "${" + logKeyPrefix + "}",
+				}},
+				"depends_on": []string{"aws_lambda_permission.s3"},
+			}},
+		},
+		Output: map[string]cwgen.TerraformOutput{
+			"function_arn":          {Description: "ARN of the function", Value: "${aws_lambda_function.function.arn}"},
+			"role_arn":              {Description: "ARN of the function's role", Value: "${aws_iam_role.function.arn}"},
+			"dead_letter_queue_url": {Description: "Queue that receives events the function failed to process", Value: "${aws_sqs_queue.dead_letter.url}"},
+		},
+	}
+}
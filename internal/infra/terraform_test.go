@@ -0,0 +1,70 @@
+package infra
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerraform(t *testing.T) {
+	config := Terraform()
+
+	function := config.Resource["aws_lambda_function"]["function"].(object)
+	assert.Equal(t, []string{"arm64"}, function["architectures"])
+	assert.Equal(t, "provided.al2023", function["runtime"])
+	assert.Equal(t, object{"target_arn": "${aws_sqs_queue.dead_letter.arn}"}, function["dead_letter_config"])
+	assert.Equal(t, "${var.layers}", function["layers"])
+	assert.Equal(t, []string{}, config.Variable["layers"].Default)
+
+	variables := function["environment"].(object)["variables"].(object)
+	assert.Len(t, variables, len(settings))
+	assert.Equal(t, "${var.include_path_rules}", variables["INCLUDE_PATH_RULES"])
+
+	assert.Nil(t, config.Variable["include_path_rules"].Default, "the rules are required")
+	assert.Nil(t, config.Variable["package_file"].Default, "the package is required")
+	assert.Equal(t, "", config.Variable["dry_run"].Default)
+
+	assert.Equal(t, `${compact(["ALBAccessLog", var.pipeline_metrics_namespace])}`, config.Locals["namespaces"])
+	assert.Equal(t, "${var.manage_bucket_notification ? 1 : 0}", config.Resource["aws_s3_bucket_notification"]["logs"].(object)["count"])
+}
+
+// TestTerraform_References checks that every reference in the module resolves, since the module cannot be
+// validated with terraform in tests.
+func TestTerraform_References(t *testing.T) {
+	config := Terraform()
+
+	b, err := json.Marshal(config)
+	require.NoError(t, err)
+
+	declared := func(kind, name string) bool {
+		switch kind {
+		case "var":
+			_, ok := config.Variable[name]
+			return ok
+		case "local":
+			_, ok := config.Locals[name]
+			return ok
+		case "data":
+			typ, n, _ := strings.Cut(name, ".")
+			_, ok := config.Data[typ][n]
+			return ok
+		default:
+			_, ok := config.Resource[kind][name]
+			return ok
+		}
+	}
+
+	references := regexp.MustCompile(`\b(var|local|data|aws_[a-z0-9_]+)\.([a-z0-9_]+(?:\.[a-z0-9_]+)?)`)
+	for _, m := range references.FindAllStringSubmatch(string(b), -1) {
+		name := m[2]
+		if m[1] != "data" {
+			// Drop the attribute, such as the arn of aws_sqs_queue.dead_letter.arn.
+			name, _, _ = strings.Cut(name, ".")
+		}
+		assert.True(t, declared(m[1], name), "reference to undeclared %s", m[0])
+	}
+}
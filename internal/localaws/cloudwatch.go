@@ -0,0 +1,434 @@
+package localaws
+
+import (
+	"cmp"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cloudWatchNamespace = "http://monitoring.amazonaws.com/doc/2010-08-01/"
+
+	// CloudWatch request limits enforced by PutMetricData.
+	maxDatumsPerPut   = 1000
+	maxValuesPerDatum = 150
+)
+
+// Datum is a metric datum accepted by PutMetricData.
+type Datum struct {
+	Namespace  string            `json:"namespace"`
+	MetricName string            `json:"metric_name"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Value      *float64          `json:"value,omitempty"`
+	Values     []float64         `json:"values,omitempty"`
+	Counts     []float64         `json:"counts,omitempty"`
+	Unit       string            `json:"unit,omitempty"`
+}
+
+// samples returns the datum's values with their counts.
+func (d Datum) samples() ([]float64, []float64) {
+	if d.Value != nil {
+		return []float64{*d.Value}, []float64{1}
+	}
+
+	counts := d.Counts
+	if len(counts) == 0 {
+		counts = make([]float64, len(d.Values))
+		for i := range counts {
+			counts[i] = 1
+		}
+	}
+	return d.Values, counts
+}
+
+type queryError struct {
+	code    string
+	message string
+}
+
+func (e *queryError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func invalidParameter(format string, args ...any) error {
+	return &queryError{code: "InvalidParameterValue", message: fmt.Sprintf(format, args...)}
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Xmlns     string   `xml:"xmlns,attr"`
+	Error     errorBody
+	RequestID string `xml:"RequestId"`
+}
+
+type errorBody struct {
+	XMLName xml.Name `xml:"Error"`
+	Type    string   `xml:"Type"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+type responseMetadata struct {
+	RequestID string `xml:"RequestId"`
+}
+
+type putMetricDataResponse struct {
+	XMLName          xml.Name         `xml:"PutMetricDataResponse"`
+	Xmlns            string           `xml:"xmlns,attr"`
+	ResponseMetadata responseMetadata `xml:"ResponseMetadata"`
+}
+
+type getMetricDataResponse struct {
+	XMLName          xml.Name            `xml:"GetMetricDataResponse"`
+	Xmlns            string              `xml:"xmlns,attr"`
+	Result           getMetricDataResult `xml:"GetMetricDataResult"`
+	ResponseMetadata responseMetadata    `xml:"ResponseMetadata"`
+}
+
+type getMetricDataResult struct {
+	MetricDataResults []metricDataResult `xml:"MetricDataResults>member"`
+}
+
+type metricDataResult struct {
+	ID         string    `xml:"Id"`
+	Label      string    `xml:"Label"`
+	StatusCode string    `xml:"StatusCode"`
+	Timestamps []string  `xml:"Timestamps>member"`
+	Values     []float64 `xml:"Values>member"`
+}
+
+// serveCloudWatch answers awsQuery requests for PutMetricData and GetMetricData.
+func (s *Server) serveCloudWatch(w http.ResponseWriter, r *http.Request) {
+	form, err := readForm(r)
+	if err != nil {
+		s.writeQueryError(w, &queryError{code: "MalformedQueryString", message: err.Error()})
+		return
+	}
+
+	switch action := form.Get("Action"); action {
+	case "PutMetricData":
+		err = s.putMetricData(form)
+		if err == nil {
+			s.writeXML(w, http.StatusOK, putMetricDataResponse{Xmlns: cloudWatchNamespace, ResponseMetadata: responseMetadata{RequestID: requestID()}})
+		}
+	case "GetMetricData":
+		var result getMetricDataResult
+		result, err = s.getMetricData(form)
+		if err == nil {
+			s.writeXML(w, http.StatusOK, getMetricDataResponse{Xmlns: cloudWatchNamespace, Result: result, ResponseMetadata: responseMetadata{RequestID: requestID()}})
+		}
+	default:
+		err = &queryError{code: "InvalidAction", message: fmt.Sprintf("action %q is not supported", action)}
+	}
+
+	if err != nil {
+		s.writeQueryError(w, err)
+	}
+}
+
+// readForm decodes the form body, which the SDK gzip-compresses for large PutMetricData requests.
+func readForm(r *http.Request) (url.Values, error) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress body: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return url.ParseQuery(string(raw))
+}
+
+func (s *Server) putMetricData(form url.Values) error {
+	namespace := form.Get("Namespace")
+	if namespace == "" {
+		return invalidParameter("Namespace is required")
+	}
+
+	var datums []Datum
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("MetricData.member.%d.", i)
+		name := form.Get(prefix + "MetricName")
+		if name == "" {
+			break
+		}
+		if i > maxDatumsPerPut {
+			return invalidParameter("MetricData must not contain more than %d items", maxDatumsPerPut)
+		}
+
+		datum, err := parseDatum(form, prefix)
+		if err != nil {
+			return err
+		}
+		datum.Namespace = namespace
+		datum.MetricName = name
+		datums = append(datums, datum)
+	}
+	if len(datums) == 0 {
+		return invalidParameter("MetricData is required")
+	}
+
+	s.mu.Lock()
+	s.datums = append(s.datums, datums...)
+	s.mu.Unlock()
+
+	s.logger.Info("stored metric data", "namespace", namespace, "datums", len(datums))
+	return nil
+}
+
+func parseDatum(form url.Values, prefix string) (Datum, error) {
+	datum := Datum{
+		Dimensions: parseDimensions(form, prefix+"Dimensions.member."),
+		Unit:       form.Get(prefix + "Unit"),
+		Timestamp:  time.Now().UTC(),
+	}
+
+	if raw := form.Get(prefix + "Timestamp"); raw != "" {
+		ts, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return Datum{}, invalidParameter("%sTimestamp: %v", prefix, err)
+		}
+		datum.Timestamp = ts.UTC()
+	}
+
+	if raw := form.Get(prefix + "Value"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Datum{}, invalidParameter("%sValue: %v", prefix, err)
+		}
+		datum.Value = &v
+	}
+
+	var err error
+	if datum.Values, err = parseFloats(form, prefix+"Values.member."); err != nil {
+		return Datum{}, err
+	}
+	if datum.Counts, err = parseFloats(form, prefix+"Counts.member."); err != nil {
+		return Datum{}, err
+	}
+
+	switch {
+	case datum.Value != nil && len(datum.Values) > 0:
+		return Datum{}, invalidParameter("%s: Value and Values are mutually exclusive", strings.TrimSuffix(prefix, "."))
+	case len(datum.Values) > maxValuesPerDatum:
+		return Datum{}, invalidParameter("%sValues must not contain more than %d items", prefix, maxValuesPerDatum)
+	case len(datum.Counts) > 0 && len(datum.Counts) != len(datum.Values):
+		return Datum{}, invalidParameter("%s: Values and Counts must have the same length", strings.TrimSuffix(prefix, "."))
+	}
+
+	return datum, nil
+}
+
+func parseDimensions(form url.Values, prefix string) map[string]string {
+	var dimensions map[string]string
+	for i := 1; ; i++ {
+		name := form.Get(fmt.Sprintf("%s%d.Name", prefix, i))
+		if name == "" {
+			return dimensions
+		}
+		if dimensions == nil {
+			dimensions = make(map[string]string)
+		}
+		dimensions[name] = form.Get(fmt.Sprintf("%s%d.Value", prefix, i))
+	}
+}
+
+func parseFloats(form url.Values, prefix string) ([]float64, error) {
+	var values []float64
+	for i := 1; ; i++ {
+		raw := form.Get(fmt.Sprintf("%s%d", prefix, i))
+		if raw == "" {
+			return values, nil
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, invalidParameter("%s%d: %v", prefix, i, err)
+		}
+		values = append(values, v)
+	}
+}
+
+// getMetricData evaluates MetricStat queries over the stored data. Metric math expressions are not supported.
+func (s *Server) getMetricData(form url.Values) (getMetricDataResult, error) {
+	start, err := time.Parse(time.RFC3339Nano, form.Get("StartTime"))
+	if err != nil {
+		return getMetricDataResult{}, invalidParameter("StartTime: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339Nano, form.Get("EndTime"))
+	if err != nil {
+		return getMetricDataResult{}, invalidParameter("EndTime: %v", err)
+	}
+	ascending := form.Get("ScanBy") == "TimestampAscending"
+
+	datums := s.Datums()
+
+	var result getMetricDataResult
+	for i := 1; ; i++ {
+		prefix := fmt.Sprintf("MetricDataQueries.member.%d.", i)
+		id := form.Get(prefix + "Id")
+		if id == "" {
+			break
+		}
+		if form.Get(prefix+"ReturnData") == "false" {
+			continue
+		}
+		if form.Get(prefix+"Expression") != "" {
+			return getMetricDataResult{}, invalidParameter("%s: metric math expressions are not supported", id)
+		}
+
+		period, err := strconv.Atoi(form.Get(prefix + "MetricStat.Period"))
+		if err != nil || period <= 0 {
+			return getMetricDataResult{}, invalidParameter("%s: MetricStat.Period must be a positive integer", id)
+		}
+
+		metric := Datum{
+			Namespace:  form.Get(prefix + "MetricStat.Metric.Namespace"),
+			MetricName: form.Get(prefix + "MetricStat.Metric.MetricName"),
+			Dimensions: parseDimensions(form, prefix+"MetricStat.Metric.Dimensions.member."),
+		}
+		stat := form.Get(prefix + "MetricStat.Stat")
+
+		series, err := aggregate(datums, metric, stat, start, end, time.Duration(period)*time.Second, ascending)
+		if err != nil {
+			return getMetricDataResult{}, invalidParameter("%s: %v", id, err)
+		}
+		series.ID = id
+		series.Label = cmp.Or(form.Get(prefix+"Label"), metric.MetricName)
+		result.MetricDataResults = append(result.MetricDataResults, series)
+	}
+
+	return result, nil
+}
+
+// aggregate computes stat per period for the data points of a single metric between start and end.
+func aggregate(datums []Datum, metric Datum, stat string, start, end time.Time, period time.Duration, ascending bool) (metricDataResult, error) {
+	type bucket struct {
+		values []float64
+		counts []float64
+	}
+	buckets := make(map[time.Time]*bucket)
+
+	for _, d := range datums {
+		if d.Namespace != metric.Namespace || d.MetricName != metric.MetricName || !maps.Equal(d.Dimensions, metric.Dimensions) {
+			continue
+		}
+		if d.Timestamp.Before(start) || !d.Timestamp.Before(end) {
+			continue
+		}
+
+		ts := d.Timestamp.Truncate(period)
+		b, ok := buckets[ts]
+		if !ok {
+			b = &bucket{}
+			buckets[ts] = b
+		}
+		values, counts := d.samples()
+		b.values = append(b.values, values...)
+		b.counts = append(b.counts, counts...)
+	}
+
+	timestamps := slices.SortedFunc(maps.Keys(buckets), func(a, b time.Time) int { return a.Compare(b) })
+	if !ascending {
+		slices.Reverse(timestamps)
+	}
+
+	result := metricDataResult{StatusCode: "Complete"}
+	for _, ts := range timestamps {
+		v, err := statistic(stat, buckets[ts].values, buckets[ts].counts)
+		if err != nil {
+			return metricDataResult{}, err
+		}
+		result.Timestamps = append(result.Timestamps, ts.UTC().Format(time.RFC3339))
+		result.Values = append(result.Values, v)
+	}
+	return result, nil
+}
+
+// statistic computes Sum, SampleCount, Average, Minimum, Maximum or a pNN percentile over weighted values.
+func statistic(stat string, values, counts []float64) (float64, error) {
+	var sum, total float64
+	minimum, maximum := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		sum += v * counts[i]
+		total += counts[i]
+		minimum = min(minimum, v)
+		maximum = max(maximum, v)
+	}
+
+	switch stat {
+	case "Sum":
+		return sum, nil
+	case "SampleCount":
+		return total, nil
+	case "Average":
+		return sum / total, nil
+	case "Minimum":
+		return minimum, nil
+	case "Maximum":
+		return maximum, nil
+	}
+
+	raw, ok := strings.CutPrefix(stat, "p")
+	if !ok {
+		return 0, fmt.Errorf("statistic %q is not supported", stat)
+	}
+	p, err := strconv.ParseFloat(raw, 64)
+	if err != nil || p < 0 || p > 100 {
+		return 0, fmt.Errorf("statistic %q is not supported", stat)
+	}
+
+	// Nearest-rank percentile over the values expanded by their counts.
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if values[a] < values[b] {
+			return -1
+		}
+		if values[a] > values[b] {
+			return 1
+		}
+		return 0
+	})
+
+	rank := math.Max(1, math.Ceil(p/100*total))
+	var seen float64
+	for _, i := range order {
+		seen += counts[i]
+		if seen >= rank {
+			return values[i], nil
+		}
+	}
+	return maximum, nil
+}
+
+func (s *Server) writeQueryError(w http.ResponseWriter, err error) {
+	qe, ok := err.(*queryError)
+	if !ok {
+		qe = &queryError{code: "InternalFailure", message: err.Error()}
+	}
+
+	s.writeXML(w, http.StatusBadRequest, errorResponse{
+		Xmlns:     cloudWatchNamespace,
+		Error:     errorBody{Type: "Sender", Code: qe.code, Message: qe.message},
+		RequestID: requestID(),
+	})
+}
@@ -0,0 +1,207 @@
+package localaws
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	defaultMaxKeys = 1000
+)
+
+type s3Error struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	KeyCount              *int           `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectResult `xml:"Contents"`
+}
+
+type objectResult struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// serveS3 answers path-style GetObject, HeadObject, ListObjects and ListObjectsV2 requests.
+func (s *Server) serveS3(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		s.writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "bucket name is required", r.URL.Path)
+		return
+	}
+
+	bucketDir, ok := s.bucketDir(bucket)
+	if !ok {
+		s.writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist", bucket)
+		return
+	}
+
+	if key == "" {
+		s.listObjects(w, r, bucket, bucketDir)
+		return
+	}
+	s.getObject(w, r, bucketDir, key)
+}
+
+// bucketDir returns the directory holding the bucket's objects.
+func (s *Server) bucketDir(bucket string) (string, bool) {
+	if bucket == "." || bucket == ".." || strings.ContainsAny(bucket, `/\`) {
+		return "", false
+	}
+
+	dir := filepath.Join(s.dir, bucket)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucketDir, key string) {
+	clean := path.Clean("/" + key)
+	if clean != "/"+key {
+		s.writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "object keys with . or .. segments are not supported", key)
+		return
+	}
+
+	body, err := os.ReadFile(filepath.Join(bucketDir, filepath.FromSlash(key)))
+	if err != nil {
+		s.writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.", key)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket, bucketDir string) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	v2 := query.Get("list-type") == "2"
+
+	maxKeys := defaultMaxKeys
+	if raw := query.Get("max-keys"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			s.writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer", bucket)
+			return
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+
+	// Keys are returned in lexicographic order after the marker, as S3 does.
+	after := query.Get("marker")
+	if v2 {
+		after = max(query.Get("continuation-token"), query.Get("start-after"))
+	}
+
+	var objects []objectResult
+	err := filepath.WalkDir(bucketDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(bucketDir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || key <= after {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, objectResult{
+			Key:          key,
+			LastModified: info.ModTime().UTC().Format(time.RFC3339),
+			ETag:         etag(body),
+			Size:         info.Size(),
+			StorageClass: "STANDARD",
+		})
+		return nil
+	})
+	if err != nil {
+		s.writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error(), bucket)
+		return
+	}
+	slices.SortFunc(objects, func(a, b objectResult) int { return strings.Compare(a.Key, b.Key) })
+
+	result := listBucketResult{Xmlns: s3Namespace, Name: bucket, Prefix: prefix, MaxKeys: maxKeys}
+	if len(objects) > maxKeys {
+		objects = objects[:maxKeys]
+		result.IsTruncated = true
+	}
+	result.Contents = objects
+
+	var last string
+	if len(objects) > 0 {
+		last = objects[len(objects)-1].Key
+	}
+	if v2 {
+		keyCount := len(objects)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = query.Get("start-after")
+		if result.IsTruncated {
+			result.NextContinuationToken = last
+		}
+	} else {
+		marker := query.Get("marker")
+		result.Marker = &marker
+		if result.IsTruncated {
+			result.NextMarker = last
+		}
+	}
+
+	s.writeXML(w, http.StatusOK, result)
+}
+
+func (s *Server) writeS3Error(w http.ResponseWriter, status int, code, message, resource string) {
+	s.writeXML(w, status, s3Error{Code: code, Message: message, Resource: resource, RequestID: requestID()})
+}
+
+// etag returns the quoted MD5 digest S3 uses as the ETag of single-part objects.
+func etag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
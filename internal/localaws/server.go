@@ -0,0 +1,103 @@
+// Package localaws serves the small subset of the S3 and CloudWatch APIs the pipeline uses, so that
+// the Lambda function and its tooling can run without AWS.
+//
+// S3 objects are read from a directory in which each subdirectory is a bucket. Metric data is kept
+// in memory and can be read back with GetMetricData or as JSON from /_localaws/metrics.
+package localaws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// metricsPath serves the stored metric data as JSON; DELETE clears it.
+const metricsPath = "/_localaws/metrics"
+
+// Server is an http.Handler implementing the S3 and CloudWatch stand-ins.
+type Server struct {
+	dir    string
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	datums []Datum
+}
+
+// NewServer returns a Server that serves buckets from the subdirectories of dir.
+func NewServer(dir string, logger *slog.Logger) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Server{dir: dir, logger: logger}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("request", "method", r.Method, "url", r.URL.String())
+
+	switch {
+	case r.URL.Path == metricsPath:
+		s.serveDatums(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/":
+		s.serveCloudWatch(w, r)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.serveS3(w, r)
+	default:
+		http.Error(w, "unsupported request", http.StatusMethodNotAllowed)
+	}
+}
+
+// Datums returns a copy of every datum accepted by PutMetricData.
+func (s *Server) Datums() []Datum {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	datums := make([]Datum, len(s.datums))
+	copy(datums, s.datums)
+	return datums
+}
+
+// Reset discards the stored metric data.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.datums = nil
+}
+
+func (s *Server) serveDatums(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.Datums()); err != nil {
+			s.logger.Error("failed to write metric data", "error", err)
+		}
+	case http.MethodDelete:
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported request", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeXML writes an XML response body with the given status.
+func (s *Server) writeXML(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return
+	}
+	if err := xml.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error("failed to write response", "error", err)
+	}
+}
+
+func requestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
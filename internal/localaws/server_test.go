@@ -0,0 +1,264 @@
+package localaws
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/albgen"
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/metrics"
+)
+
+// newTestClients starts a Server over dir and returns SDK clients pointed at it.
+func newTestClients(t *testing.T, dir string) (*Server, *s3.Client, *cloudwatch.Client, string) {
+	t.Helper()
+
+	server := NewServer(dir, nil)
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(ts.URL),
+	}
+	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = true })
+	return server, s3Client, cloudwatch.NewFromConfig(cfg), ts.URL
+}
+
+func writeObject(t *testing.T, dir, bucket, key, body string) {
+	t.Helper()
+
+	p := filepath.Join(dir, bucket, filepath.FromSlash(key))
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	require.NoError(t, os.WriteFile(p, []byte(body), 0o644))
+}
+
+func TestServer_S3(t *testing.T) {
+	dir := t.TempDir()
+	writeObject(t, dir, "logs", "AWSLogs/a.log", "first")
+	writeObject(t, dir, "logs", "AWSLogs/b.log", "second")
+	writeObject(t, dir, "logs", "other/c.log", "third")
+	_, client, _, _ := newTestClients(t, dir)
+	ctx := context.Background()
+
+	t.Run("get object", func(t *testing.T) {
+		out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("logs"), Key: aws.String("AWSLogs/b.log")})
+		require.NoError(t, err)
+		defer out.Body.Close()
+
+		body, err := io.ReadAll(out.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "second", string(body))
+	})
+
+	t.Run("no such key", func(t *testing.T) {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("logs"), Key: aws.String("AWSLogs/missing.log")})
+		var noSuchKey *s3types.NoSuchKey
+		assert.ErrorAs(t, err, &noSuchKey)
+	})
+
+	t.Run("no such bucket", func(t *testing.T) {
+		_, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("missing"), Key: aws.String("a.log")})
+		assert.ErrorContains(t, err, "NoSuchBucket")
+	})
+
+	t.Run("list objects with prefix and pagination", func(t *testing.T) {
+		var keys []string
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket:  aws.String("logs"),
+			Prefix:  aws.String("AWSLogs/"),
+			MaxKeys: aws.Int32(1),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			require.NoError(t, err)
+			for _, obj := range page.Contents {
+				keys = append(keys, aws.ToString(obj.Key))
+			}
+		}
+		assert.Equal(t, []string{"AWSLogs/a.log", "AWSLogs/b.log"}, keys)
+	})
+}
+
+func TestServer_S3RejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	writeObject(t, dir, "logs", "a.log", "first")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o644))
+	_, _, _, endpoint := newTestClients(t, filepath.Join(dir, "logs"))
+
+	for _, p := range []string{"/logs/../secret", "/../secret", "/%2e%2e/secret"} {
+		resp, err := http.Get(endpoint + p)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.NotEqual(t, http.StatusOK, resp.StatusCode, p)
+	}
+}
+
+func TestServer_CloudWatch(t *testing.T) {
+	server, _, client, endpoint := newTestClients(t, t.TempDir())
+	ctx := context.Background()
+	minute := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dimensions := []cwtypes.Dimension{
+		{Name: aws.String("Method"), Value: aws.String("GET")},
+		{Name: aws.String("Path"), Value: aws.String("/users/:id")},
+	}
+
+	_, err := client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String("ALBAccessLog"),
+		MetricData: []cwtypes.MetricDatum{
+			{MetricName: aws.String("RequestCount"), Dimensions: dimensions, Timestamp: aws.Time(minute), Value: aws.Float64(3), Unit: cwtypes.StandardUnitCount},
+			{MetricName: aws.String("RequestCount"), Dimensions: dimensions, Timestamp: aws.Time(minute.Add(30 * time.Second)), Value: aws.Float64(2), Unit: cwtypes.StandardUnitCount},
+			{MetricName: aws.String("RequestCount"), Dimensions: dimensions, Timestamp: aws.Time(minute.Add(time.Minute)), Value: aws.Float64(4), Unit: cwtypes.StandardUnitCount},
+			{MetricName: aws.String("RequestCount"), Dimensions: dimensions[:1], Timestamp: aws.Time(minute), Value: aws.Float64(100), Unit: cwtypes.StandardUnitCount},
+			{
+				MetricName: aws.String("TargetResponseTime"),
+				Dimensions: dimensions,
+				Timestamp:  aws.Time(minute),
+				Values:     []float64{0.1, 0.2, 0.9},
+				Counts:     []float64{5, 4, 1},
+				Unit:       cwtypes.StandardUnitSeconds,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	datums := server.Datums()
+	require.Len(t, datums, 5)
+	assert.Equal(t, "ALBAccessLog", datums[0].Namespace)
+	assert.Equal(t, map[string]string{"Method": "GET", "Path": "/users/:id"}, datums[0].Dimensions)
+	assert.Equal(t, minute, datums[0].Timestamp)
+	assert.Equal(t, []float64{5, 4, 1}, datums[4].Counts)
+	assert.Equal(t, "Seconds", datums[4].Unit)
+
+	query := func(id, metricName, stat string) cwtypes.MetricDataQuery {
+		return cwtypes.MetricDataQuery{
+			Id: aws.String(id),
+			MetricStat: &cwtypes.MetricStat{
+				Metric: &cwtypes.Metric{Namespace: aws.String("ALBAccessLog"), MetricName: aws.String(metricName), Dimensions: dimensions},
+				Period: aws.Int32(60),
+				Stat:   aws.String(stat),
+			},
+		}
+	}
+	out, err := client.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(minute),
+		EndTime:   aws.Time(minute.Add(time.Hour)),
+		ScanBy:    cwtypes.ScanByTimestampAscending,
+		MetricDataQueries: []cwtypes.MetricDataQuery{
+			query("requests", "RequestCount", "Sum"),
+			query("p50", "TargetResponseTime", "p50"),
+			query("p95", "TargetResponseTime", "p95"),
+			query("samples", "TargetResponseTime", "SampleCount"),
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, out.MetricDataResults, 4)
+
+	requests := out.MetricDataResults[0]
+	assert.Equal(t, "requests", aws.ToString(requests.Id))
+	assert.Equal(t, []time.Time{minute, minute.Add(time.Minute)}, requests.Timestamps)
+	assert.Equal(t, []float64{5, 4}, requests.Values)
+	assert.Equal(t, []float64{0.1}, out.MetricDataResults[1].Values)
+	assert.Equal(t, []float64{0.9}, out.MetricDataResults[2].Values)
+	assert.Equal(t, []float64{10}, out.MetricDataResults[3].Values)
+
+	resp, err := http.Get(endpoint + metricsPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var fromJSON []Datum
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fromJSON))
+	assert.Len(t, fromJSON, 5)
+
+	server.Reset()
+	assert.Empty(t, server.Datums())
+}
+
+func TestServer_CloudWatchErrors(t *testing.T) {
+	_, _, client, _ := newTestClients(t, t.TempDir())
+	ctx := context.Background()
+
+	_, err := client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String("ALBAccessLog"),
+		MetricData: []cwtypes.MetricDatum{
+			{MetricName: aws.String("TargetResponseTime"), Values: []float64{1, 2}, Counts: []float64{1}},
+		},
+	})
+	assert.ErrorContains(t, err, "InvalidParameterValue")
+
+	_, err = client.ListMetrics(ctx, &cloudwatch.ListMetricsInput{})
+	assert.ErrorContains(t, err, "InvalidAction")
+}
+
+// TestServer_Pipeline runs the processor against the stand-in with the scenario fixture from the metrics package.
+func TestServer_Pipeline(t *testing.T) {
+	const fixture = "../metrics/testdata/scenario"
+
+	server, s3Client, cwClient, _ := newTestClients(t, fixture)
+
+	rawRules, err := os.ReadFile(filepath.Join(fixture, "rules.json"))
+	require.NoError(t, err)
+	rules, err := metrics.NewPathRules(string(rawRules))
+	require.NoError(t, err)
+
+	var event events.S3Event
+	logs := filepath.Join(fixture, "logs")
+	err = filepath.WalkDir(logs, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(logs, p)
+		if err != nil {
+			return err
+		}
+		event.Records = append(event.Records, events.S3EventRecord{S3: events.S3Entity{
+			Bucket: events.S3Bucket{Name: "logs"},
+			Object: events.S3Object{Key: filepath.ToSlash(rel)},
+		}})
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, event.Records)
+
+	processor := metrics.NewProcwessor(s3Client, cwClient, rules, metrics.ProcessorOptions{})
+	require.NoError(t, processor.HandleEvent(context.Background(), event))
+
+	expected, err := albgen.LoadExpectedMetrics(filepath.Join(fixture, "expected.json"))
+	require.NoError(t, err)
+
+	var wantRequests, wantFailed int
+	for _, m := range expected.Metrics {
+		wantRequests += m.RequestCount
+		wantFailed += m.FailedRequestCount
+	}
+
+	var gotRequests, gotFailed float64
+	for _, d := range server.Datums() {
+		require.Equal(t, "ALBAccessLog", d.Namespace)
+		switch d.MetricName {
+		case "RequestCount":
+			gotRequests += *d.Value
+		case "FailedRequestCount":
+			gotFailed += *d.Value
+		}
+	}
+	assert.Equal(t, float64(wantRequests), gotRequests)
+	assert.Equal(t, float64(wantFailed), gotFailed)
+}
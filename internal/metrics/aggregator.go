@@ -1,6 +1,11 @@
 package metrics
 
 import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -11,12 +16,33 @@ const (
 	metricNameTargetResponseTime = "TargetResponseTime"
 	metricNameRequestCount       = "RequestCount"
 	metricNameFailedRequestCount = "FailedRequestCount"
+	metricNameReceivedBytes      = "ReceivedBytes"
+	metricNameSentBytes          = "SentBytes"
+	metricNameThroughput         = "Throughput"
+	metricNameTargetUnavailable  = "TargetUnavailableCount"
+
+	metricNameUnmatchedRequestCount = "UnmatchedRequestCount"
 
 	metricDimensionMethod = "Method"
 	metricDimensionHost   = "Host"
 	metricDimensionPath   = "Path"
+	metricDimensionTarget = "Target"
 
 	maxMetricValues = 150
+
+	// defaultMaxTargets is the number of distinct Target values published per Method/Host/Path.
+	defaultMaxTargets = 20
+)
+
+// Names of the path metrics and their dimensions, for tools that build dashboards and alarms on them.
+const (
+	MetricNameTargetResponseTime = metricNameTargetResponseTime
+	MetricNameRequestCount       = metricNameRequestCount
+	MetricNameFailedRequestCount = metricNameFailedRequestCount
+
+	MetricDimensionMethod = metricDimensionMethod
+	MetricDimensionHost   = metricDimensionHost
+	MetricDimensionPath   = metricDimensionPath
 )
 
 type metricKey struct {
@@ -24,30 +50,146 @@ type metricKey struct {
 	Host   string
 	Path   string
 	Minute time.Time
+	// Target is the target:port of the per-target breakdown, empty for the path-level aggregate.
+	Target string
+	// Segment is the extra dimension of a breakdown such as ClientType or Country, empty otherwise.
+	Segment segment
+}
+
+// segment is a dimension that a rule publishes its metrics by in addition to the path-level metrics.
+type segment struct {
+	Name  string
+	Value string
+}
+
+func compareMetricKeys(a, b metricKey) int {
+	return cmp.Or(
+		a.Minute.Compare(b.Minute),
+		strings.Compare(a.Host, b.Host),
+		strings.Compare(a.Path, b.Path),
+		strings.Compare(a.Method, b.Method),
+		strings.Compare(a.Target, b.Target),
+		strings.Compare(a.Segment.Name, b.Segment.Name),
+		strings.Compare(a.Segment.Value, b.Segment.Value),
+	)
+}
+
+// pathKey identifies a Method/Host/Path across minutes.
+type pathKey struct {
+	Method string
+	Host   string
+	Path   string
+}
+
+type hostMinuteKey struct {
+	Host   string
+	Minute time.Time
 }
 
 type metricAggregate struct {
+	// metrics is the selection published for the key; nil selects the aggregator's defaults.
+	metrics []metricSpec
+
 	targetResponseTime []float64
+	receivedBytes      []float64
+	sentBytes          []float64
+	// throughput is the bytes received and sent per second of target processing time.
+	throughput         []float64
 	requestCount       int
 	failedRequestCount int
+	// targetUnavailableCount counts requests routed to a target that never responded.
+	targetUnavailableCount int
+
+	// ALB field values counted per request; see reason_metrics.go.
+	errorReasons          map[string]int
+	actionsExecuted       map[string]int
+	classifications       map[string]int
+	classificationReasons map[string]int
 }
 
 // metricAggregator maintains per method/host/path aggregates convertible to CloudWatch MetricDatum values.
 type metricAggregator struct {
-	metrics map[metricKey]*metricAggregate
+	metrics   map[metricKey]*metricAggregate
+	unmatched map[hostMinuteKey]int
+
+	// defaultMetrics is published for rules without their own selection; nil selects defaultMetricSpecs.
+	defaultMetrics []metricSpec
+	// unavailableAsFailed counts requests whose target was unavailable as failed regardless of their status.
+	unavailableAsFailed bool
+
+	// targets holds the Target values published per Method/Host/Path, at most maxTargets of them;
+	// later targets are published as Other. Zero maxTargets selects defaultMaxTargets.
+	targets    map[pathKey]map[string]bool
+	maxTargets int
+
+	// tls counts requests per host, TLS protocol and cipher; see tls_metrics.go.
+	tls           map[tlsKey]int
+	tlsLegacyOnly bool
+
+	// slos counts good and total requests per SLO; see slo.go.
+	slos map[sloKey]*sloAggregate
 }
 
 // Record adds a single request observation to the aggregate identified by the rule name.
 func (m *metricAggregator) Record(entry albLogEntry, name string) {
+	m.recordMetrics(entry, name, nil, false, nil)
+}
+
+// recordMetrics is Record for a rule with its own metric selection. When several rules share a
+// Method/Host/Path, the selection of the first one recorded applies. withTarget also records the
+// entry in a per-target aggregate with the Target dimension, and each segment in an aggregate with
+// the segment's dimension.
+func (m *metricAggregator) recordMetrics(entry albLogEntry, name string, specs []metricSpec, withTarget bool, segments []segment) {
 	if name == "" {
 		return
 	}
 
 	minute := entry.timestamp.UTC().Truncate(time.Minute)
 	key := metricKey{Method: entry.method, Host: entry.host, Path: name, Minute: minute}
+	m.record(key, entry, specs)
+
+	if withTarget {
+		key.Target = m.targetValue(pathKey{Method: key.Method, Host: key.Host, Path: key.Path}, entry.target)
+		m.record(key, entry, specs)
+	}
+
+	key.Target = ""
+	for _, seg := range segments {
+		key.Segment = seg
+		m.record(key, entry, specs)
+	}
+}
+
+// targetValue returns the Target dimension value for target, folding targets beyond the cap into Other.
+func (m *metricAggregator) targetValue(path pathKey, target string) string {
+	if m.targets == nil {
+		m.targets = make(map[pathKey]map[string]bool)
+	}
+	seen := m.targets[path]
+	if seen == nil {
+		seen = make(map[string]bool)
+		m.targets[path] = seen
+	}
+
+	if seen[target] {
+		return target
+	}
+	maxTargets := m.maxTargets
+	if maxTargets <= 0 {
+		maxTargets = defaultMaxTargets
+	}
+	if len(seen) >= maxTargets {
+		return otherFieldValue
+	}
+	seen[target] = true
+	return target
+}
+
+// record adds the entry to the aggregate for key.
+func (m *metricAggregator) record(key metricKey, entry albLogEntry, specs []metricSpec) {
 	agg, ok := m.metrics[key]
 	if !ok {
-		agg = &metricAggregate{}
+		agg = &metricAggregate{metrics: specs}
 		m.metrics[key] = agg
 	}
 
@@ -56,17 +198,76 @@ func (m *metricAggregator) Record(entry albLogEntry, name string) {
 		agg.targetResponseTime = append(agg.targetResponseTime, entry.targetProcessingTime)
 	}
 
+	if entry.receivedBytes >= 0 && entry.sentBytes >= 0 {
+		agg.receivedBytes = append(agg.receivedBytes, float64(entry.receivedBytes))
+		agg.sentBytes = append(agg.sentBytes, float64(entry.sentBytes))
+
+		// Requests answered in under a millisecond are logged with a zero target processing time and have no throughput.
+		// Rounding to whole bytes per second keeps the number of distinct values, and so datums, low.
+		if entry.targetProcessingTime > 0 {
+			agg.throughput = append(agg.throughput, math.Round(float64(entry.receivedBytes+entry.sentBytes)/entry.targetProcessingTime))
+		}
+	}
+
+	if targetUnavailable(entry) {
+		agg.targetUnavailableCount++
+	}
+
 	agg.requestCount++
-	if entry.status >= 500 && entry.status <= 599 {
+	if m.failed(entry) {
 		agg.failedRequestCount++
 	}
+
+	countFieldValue(&agg.errorReasons, entry.errorReason)
+	for action := range strings.SplitSeq(entry.actionsExecuted, ",") {
+		countFieldValue(&agg.actionsExecuted, action)
+	}
+	countFieldValue(&agg.classifications, entry.classification)
+	countFieldValue(&agg.classificationReasons, entry.classificationReason)
 }
 
-// GetCloudWatchMetricData materializes the aggregates as CloudWatch metric data points.
+// failed reports whether the request counts as FailedRequestCount.
+func (m *metricAggregator) failed(entry albLogEntry) bool {
+	return entry.status >= 500 && entry.status <= 599 || m.unavailableAsFailed && targetUnavailable(entry)
+}
+
+// targetUnavailable reports whether a request ALB routed to a target got no response from one: a
+// processing time is -1 or no target was chosen. Requests that ALB answered itself, such as redirects,
+// fixed responses and WAF blocks, also log -1 and "-", so they only count when actions_executed shows
+// the request was forwarded or is missing from the log line.
+func targetUnavailable(entry albLogEntry) bool {
+	if entry.requestProcessingTime >= 0 && entry.targetProcessingTime >= 0 && entry.responseProcessingTime >= 0 && entry.target != "-" {
+		return false
+	}
+
+	if entry.actionsExecuted == "" || entry.actionsExecuted == "-" {
+		return true
+	}
+	for action := range strings.SplitSeq(entry.actionsExecuted, ",") {
+		if action == "forward" || action == "lambda" {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordUnmatched counts a request that no rule matched against its host.
+func (m *metricAggregator) RecordUnmatched(entry albLogEntry) {
+	if m.unmatched == nil {
+		m.unmatched = make(map[hostMinuteKey]int)
+	}
+
+	minute := entry.timestamp.UTC().Truncate(time.Minute)
+	m.unmatched[hostMinuteKey{Host: entry.host, Minute: minute}]++
+}
+
+// GetCloudWatchMetricData materializes the aggregates as CloudWatch metric data points ordered by minute.
 func (m *metricAggregator) GetCloudWatchMetricData() []types.MetricDatum {
 	var metricData []types.MetricDatum
 
-	for key, agg := range m.metrics {
+	keys := slices.SortedFunc(maps.Keys(m.metrics), compareMetricKeys)
+	for _, key := range keys {
+		agg := m.metrics[key]
 		timestamp := key.Minute
 
 		dimensions := []types.Dimension{
@@ -74,50 +275,99 @@ func (m *metricAggregator) GetCloudWatchMetricData() []types.MetricDatum {
 			{Name: aws.String(metricDimensionHost), Value: aws.String(key.Host)},
 			{Name: aws.String(metricDimensionPath), Value: aws.String(key.Path)},
 		}
-
-		valueIndex := make(map[float64]int, len(agg.targetResponseTime))
-		var values []float64
-		var counts []float64
-		for _, v := range agg.targetResponseTime {
-			if idx, ok := valueIndex[v]; ok {
-				counts[idx]++
-				continue
-			}
-
-			valueIndex[v] = len(values)
-			values = append(values, v)
-			counts = append(counts, 1.0)
+		if key.Target != "" {
+			dimensions = append(dimensions, types.Dimension{Name: aws.String(metricDimensionTarget), Value: aws.String(key.Target)})
+		}
+		if key.Segment.Name != "" {
+			dimensions = append(dimensions, types.Dimension{Name: aws.String(key.Segment.Name), Value: aws.String(key.Segment.Value)})
 		}
 
-		for start := 0; start < len(values); start += maxMetricValues {
-			end := min(start+maxMetricValues, len(values))
-			metricData = append(metricData, types.MetricDatum{
-				MetricName: aws.String(metricNameTargetResponseTime),
-				Timestamp:  aws.Time(timestamp),
-				Dimensions: dimensions,
-				Values:     values[start:end],
-				Counts:     counts[start:end],
-				Unit:       types.StandardUnitSeconds,
-			})
+		for _, spec := range m.metricSpecs(agg) {
+			metricData = appendMetricData(metricData, spec, agg, dimensions, timestamp)
 		}
+	}
 
+	unmatchedKeys := slices.SortedFunc(maps.Keys(m.unmatched), func(a, b hostMinuteKey) int {
+		return cmp.Or(a.Minute.Compare(b.Minute), strings.Compare(a.Host, b.Host))
+	})
+	for _, key := range unmatchedKeys {
 		metricData = append(metricData, types.MetricDatum{
-			MetricName: aws.String(metricNameRequestCount),
+			MetricName: aws.String(metricNameUnmatchedRequestCount),
+			Timestamp:  aws.Time(key.Minute),
+			Dimensions: []types.Dimension{
+				{Name: aws.String(metricDimensionHost), Value: aws.String(key.Host)},
+			},
+			Value: aws.Float64(float64(m.unmatched[key])),
+			Unit:  types.StandardUnitCount,
+		})
+	}
+
+	metricData = m.appendTLSMetricData(metricData)
+	return m.appendSLOMetricData(metricData)
+}
+
+// metricSpecs returns the metrics published for an aggregate.
+func (m *metricAggregator) metricSpecs(agg *metricAggregate) []metricSpec {
+	switch {
+	case agg.metrics != nil:
+		return agg.metrics
+	case m.defaultMetrics != nil:
+		return m.defaultMetrics
+	default:
+		return defaultMetricSpecs
+	}
+}
+
+// appendMetricData appends the datums of a single metric spec for one aggregate.
+func appendMetricData(metricData []types.MetricDatum, spec metricSpec, agg *metricAggregate, dimensions []types.Dimension, timestamp time.Time) []types.MetricDatum {
+	def, unit, scale, err := spec.resolve()
+	if err != nil {
+		return metricData
+	}
+
+	if def.labels != nil {
+		return appendLabeledMetricData(metricData, spec, def, agg, dimensions, timestamp)
+	}
+
+	if def.count != nil {
+		return append(metricData, types.MetricDatum{
+			MetricName: aws.String(spec.name()),
 			Timestamp:  aws.Time(timestamp),
 			Dimensions: dimensions,
-			Value:      aws.Float64(float64(agg.requestCount)),
-			Unit:       types.StandardUnitCount,
+			Value:      aws.Float64(scaleValue(def.count(agg), scale)),
+			Unit:       unit,
 		})
+	}
+
+	samples := def.samples(agg)
+	valueIndex := make(map[float64]int, len(samples))
+	var values []float64
+	var counts []float64
+	for _, v := range samples {
+		if idx, ok := valueIndex[v]; ok {
+			counts[idx]++
+			continue
+		}
 
+		valueIndex[v] = len(values)
+		values = append(values, v)
+		counts = append(counts, 1.0)
+	}
+	for i, v := range values {
+		values[i] = scaleValue(v, scale)
+	}
+
+	for start := 0; start < len(values); start += maxMetricValues {
+		end := min(start+maxMetricValues, len(values))
 		metricData = append(metricData, types.MetricDatum{
-			MetricName: aws.String(metricNameFailedRequestCount),
+			MetricName: aws.String(spec.name()),
 			Timestamp:  aws.Time(timestamp),
 			Dimensions: dimensions,
-			Value:      aws.Float64(float64(agg.failedRequestCount)),
-			Unit:       types.StandardUnitCount,
+			Values:     values[start:end],
+			Counts:     counts[start:end],
+			Unit:       unit,
 		})
 	}
-
 	return metricData
 }
 
@@ -1,11 +1,15 @@
 package metrics
 
 import (
+	"math/rand/v2"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMetricAggregator_RecordAggregatesMetrics(t *testing.T) {
@@ -136,3 +140,192 @@ func TestMetricAggregator_GetCloudWatchMetricData_GroupsDuplicateResponseTimes(t
 	assert.Equal(t, []float64{0.42, 0.58}, responseDatum.Values)
 	assert.Equal(t, []float64{3, 1}, responseDatum.Counts)
 }
+
+func TestMetricAggregator_Properties(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"},
+		{"host":"example.com","pattern":"^/$","name":"/"},
+		{"host":"admin.example.com","pattern":"^/","name":"/admin","method":"GET"}
+	]`)
+	require.NoError(t, err)
+
+	methods := []string{"GET", "POST"}
+	hosts := []string{"example.com", "admin.example.com", "other.example.com"}
+	paths := []string{"/", "/users/1", "/users/22", "/dashboard", "/users/abc"}
+	start := parseTime(t, "2024-01-15T10:00:00Z")
+
+	for seed := range uint64(50) {
+		r := rand.New(rand.NewPCG(seed, 0))
+
+		var lines []string
+		for range r.IntN(400) {
+			fields := testFields(methods[r.IntN(len(methods))], hosts[r.IntN(len(hosts))], paths[r.IntN(len(paths))])
+			fields.Time = start.Add(time.Duration(r.IntN(600)) * time.Second).Format(time.RFC3339Nano)
+			fields.ELBStatusCode = []int{200, 404, 500, 503}[r.IntN(4)]
+			fields.TargetProcessingTime = float64(r.IntN(3000)) / 1000
+			if r.IntN(10) == 0 {
+				fields.TargetProcessingTime = -1
+			}
+			lines = append(lines, fields.String())
+		}
+
+		p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true})
+		require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+		var wantLatencies int
+		for _, line := range lines {
+			entry, name, matched := p.normalizeLogLine(line)
+			if matched && name != "" && entry.targetProcessingTime >= 0 {
+				wantLatencies++
+			}
+		}
+
+		var requests, latencies float64
+		for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+			switch aws.ToString(datum.MetricName) {
+			case metricNameRequestCount:
+				requests += aws.ToFloat64(datum.Value)
+			case metricNameTargetResponseTime:
+				assert.LessOrEqual(t, len(datum.Values), maxMetricValues)
+				for _, count := range datum.Counts {
+					latencies += count
+				}
+			}
+		}
+
+		assert.Equal(t, float64(p.stats.matchedLines), requests, "seed %d: RequestCount sum", seed)
+		assert.Equal(t, float64(wantLatencies), latencies, "seed %d: TargetResponseTime count sum", seed)
+	}
+}
+
+func TestMetricAggregator_ByteMetrics(t *testing.T) {
+	aggregator := &MetricAggregator{
+		metrics: make(map[metricKey]*metricAggregate),
+		defaultMetrics: []metricSpec{
+			{Metric: metricNameReceivedBytes},
+			{Metric: metricNameSentBytes, Unit: "Kilobytes"},
+			{Metric: metricNameThroughput},
+		},
+	}
+
+	timestamp := parseTime(t, "2024-01-15T10:00:00Z")
+	aggregator.Record(albLogEntry{method: "PUT", host: "upload.example.com", status: 200, targetProcessingTime: 0.5, receivedBytes: 1000, sentBytes: 500, timestamp: timestamp}, "/upload")
+	aggregator.Record(albLogEntry{method: "PUT", host: "upload.example.com", status: 200, targetProcessingTime: 0, receivedBytes: 1000, sentBytes: 200, timestamp: timestamp}, "/upload")
+	aggregator.Record(albLogEntry{method: "PUT", host: "upload.example.com", status: 502, targetProcessingTime: -1, receivedBytes: -1, sentBytes: -1, timestamp: timestamp}, "/upload")
+
+	metricData := aggregator.GetCloudWatchMetricData()
+	require.Len(t, metricData, 3)
+
+	received := metricData[0]
+	assert.Equal(t, metricNameReceivedBytes, aws.ToString(received.MetricName))
+	assert.Equal(t, types.StandardUnitBytes, received.Unit)
+	assert.Equal(t, []float64{1000}, received.Values)
+	assert.Equal(t, []float64{2}, received.Counts)
+
+	sent := metricData[1]
+	assert.Equal(t, types.StandardUnitKilobytes, sent.Unit)
+	assert.Equal(t, []float64{0.5, 0.2}, sent.Values)
+	assert.Equal(t, []float64{1, 1}, sent.Counts)
+
+	throughput := metricData[2]
+	assert.Equal(t, metricNameThroughput, aws.ToString(throughput.MetricName))
+	assert.Equal(t, types.StandardUnitBytesSecond, throughput.Unit)
+	assert.Equal(t, []float64{3000}, throughput.Values)
+}
+
+func TestTargetUnavailable(t *testing.T) {
+	ok := albLogEntry{target: "10.0.0.1:80", requestProcessingTime: 0.001, targetProcessingTime: 0.2, responseProcessingTime: 0, actionsExecuted: "forward"}
+
+	tests := map[string]struct {
+		modify func(e *albLogEntry)
+		want   bool
+	}{
+		"responded":                {modify: func(e *albLogEntry) {}, want: false},
+		"no target":                {modify: func(e *albLogEntry) { e.target = "-" }, want: true},
+		"target timed out":         {modify: func(e *albLogEntry) { e.targetProcessingTime = -1 }, want: true},
+		"connection failed":        {modify: func(e *albLogEntry) { e.requestProcessingTime = -1 }, want: true},
+		"response not sent":        {modify: func(e *albLogEntry) { e.responseProcessingTime = -1 }, want: true},
+		"lambda target":            {modify: func(e *albLogEntry) { e.target, e.actionsExecuted = "-", "lambda" }, want: true},
+		"without actions executed": {modify: func(e *albLogEntry) { e.target, e.actionsExecuted = "-", "" }, want: true},
+		"redirect":                 {modify: func(e *albLogEntry) { e.target, e.targetProcessingTime, e.actionsExecuted = "-", -1, "redirect" }, want: false},
+		"waf block":                {modify: func(e *albLogEntry) { e.target, e.targetProcessingTime, e.actionsExecuted = "-", -1, "waf" }, want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			entry := ok
+			tt.modify(&entry)
+			assert.Equal(t, tt.want, targetUnavailable(entry))
+		})
+	}
+}
+
+func TestMetricAggregator_TargetUnavailable(t *testing.T) {
+	timestamp := parseTime(t, "2024-01-15T10:00:00Z")
+	entries := []albLogEntry{
+		{method: "GET", host: "example.com", status: 200, target: "10.0.0.1:80", targetProcessingTime: 0.1, actionsExecuted: "forward", timestamp: timestamp},
+		{method: "GET", host: "example.com", status: 503, target: "-", targetProcessingTime: -1, actionsExecuted: "forward", timestamp: timestamp},
+		{method: "GET", host: "example.com", status: 460, target: "10.0.0.1:80", targetProcessingTime: -1, actionsExecuted: "forward", timestamp: timestamp},
+	}
+
+	for _, unavailableAsFailed := range []bool{false, true} {
+		aggregator := &MetricAggregator{
+			metrics:             make(map[metricKey]*metricAggregate),
+			defaultMetrics:      []metricSpec{{Metric: metricNameFailedRequestCount}, {Metric: metricNameTargetUnavailable}},
+			unavailableAsFailed: unavailableAsFailed,
+		}
+		for _, entry := range entries {
+			aggregator.Record(entry, "/")
+		}
+
+		metricData := aggregator.GetCloudWatchMetricData()
+		require.Len(t, metricData, 2)
+		assert.Equal(t, metricNameTargetUnavailable, aws.ToString(metricData[1].MetricName))
+		assert.Equal(t, 2.0, aws.ToFloat64(metricData[1].Value))
+
+		wantFailed := 1.0
+		if unavailableAsFailed {
+			wantFailed = 2
+		}
+		assert.Equal(t, wantFailed, aws.ToFloat64(metricData[0].Value), "unavailableAsFailed=%t", unavailableAsFailed)
+	}
+}
+
+func TestProcessLines_TargetDimension(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/users$","name":"/users","metrics":["RequestCount"],"target_dimension_until":"2024-01-15T12:00:00Z"},
+		{"host":"example.com","pattern":"^/orders$","name":"/orders","metrics":["RequestCount"],"target_dimension_until":"2024-01-15T09:00:00Z"}
+	]`)
+	require.NoError(t, err)
+
+	var lines []string
+	for _, target := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1", "10.0.0.3"} {
+		for _, path := range []string{"/users", "/orders"} {
+			fields := testFields("GET", "example.com", path)
+			fields.TargetIP = target
+			lines = append(lines, fields.String())
+		}
+	}
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, MaxTargets: 2})
+	p.now = func() time.Time { return parseTime(t, "2024-01-15T10:00:00Z") }
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	got := make(map[string]float64)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		var dimensions []string
+		for _, d := range datum.Dimensions[2:] {
+			dimensions = append(dimensions, aws.ToString(d.Value))
+		}
+		got[strings.Join(dimensions, " ")] = aws.ToFloat64(datum.Value)
+	}
+
+	// The /orders window has ended, and the third /users target exceeds the cap of two.
+	assert.Equal(t, map[string]float64{
+		"/users":                    4,
+		"/users 10.0.0.1:8080":      2,
+		"/users 10.0.0.2:8080":      1,
+		"/users " + otherFieldValue: 1,
+		"/orders":                   4,
+	}, got)
+}
@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Dimensions of the client network breakdown, derived from the client:port field.
+const (
+	metricDimensionCountry = "Country"
+	metricDimensionASN     = "ASN"
+	metricDimensionNetwork = "Network"
+)
+
+// networkDimensions lists the dimensions a rule can select with network_dimensions.
+var networkDimensions = []string{metricDimensionCountry, metricDimensionASN, metricDimensionNetwork}
+
+// clientNetworksConfig represents the JSON shape used to configure client network lookups.
+type clientNetworksConfig struct {
+	Country  *geoDatabaseConfig[string] `json:"country,omitempty"`
+	ASN      *geoDatabaseConfig[uint32] `json:"asn,omitempty"`
+	Networks []networkLabelConfig       `json:"networks,omitempty"`
+}
+
+// geoDatabaseConfig names a MaxMind database and the values published from it.
+type geoDatabaseConfig[T comparable] struct {
+	Database string `json:"database"`
+	Values   []T    `json:"values"`
+}
+
+type networkLabelConfig struct {
+	CIDR  string `json:"cidr"`
+	Label string `json:"label"`
+}
+
+type networkLabel struct {
+	prefix netip.Prefix
+	label  string
+}
+
+// clientNetworks looks up the Country, ASN and Network of client addresses. Values outside the
+// allowlists, and addresses the databases do not know, are published as Other.
+type clientNetworks struct {
+	country   *maxminddb.Reader
+	countries map[string]bool
+	asn       *maxminddb.Reader
+	asns      map[uint32]bool
+	networks  []networkLabel
+}
+
+// NewClientNetworks parses the JSON configuration string and opens the databases it names.
+// It returns nil when raw is empty. Close releases the databases.
+func NewClientNetworks(raw string) (*clientNetworks, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var cfg clientNetworksConfig
+	if err := json.Unmarshal([]byte(trimmed), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse client networks JSON: %w", err)
+	}
+
+	if cfg.Country == nil && cfg.ASN == nil && len(cfg.Networks) == 0 {
+		return nil, errors.New("client networks: country, asn or networks is required")
+	}
+
+	c := &clientNetworks{}
+	if cfg.Country != nil {
+		reader, values, err := openGeoDatabase("country", cfg.Country)
+		if err != nil {
+			return nil, err
+		}
+		c.country, c.countries = reader, values
+	}
+
+	if cfg.ASN != nil {
+		reader, values, err := openGeoDatabase("asn", cfg.ASN)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.asn, c.asns = reader, values
+	}
+
+	for idx, network := range cfg.Networks {
+		prefix, err := netip.ParsePrefix(network.CIDR)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("client network %d: failed to parse cidr: %w", idx, err)
+		}
+
+		if network.Label == "" {
+			c.Close()
+			return nil, fmt.Errorf("client network %d: label is required", idx)
+		}
+
+		c.networks = append(c.networks, networkLabel{prefix: prefix.Masked(), label: network.Label})
+	}
+
+	return c, nil
+}
+
+// openGeoDatabase opens the database of a geoDatabaseConfig and returns its allowlist as a set.
+func openGeoDatabase[T comparable](name string, cfg *geoDatabaseConfig[T]) (*maxminddb.Reader, map[T]bool, error) {
+	if cfg.Database == "" {
+		return nil, nil, fmt.Errorf("client networks: %s database is required", name)
+	}
+
+	if len(cfg.Values) == 0 {
+		return nil, nil, fmt.Errorf("client networks: %s values must not be empty", name)
+	}
+	if len(cfg.Values) > maxMetricSpecValues {
+		return nil, nil, fmt.Errorf("client networks: %s values: at most %d values are allowed", name, maxMetricSpecValues)
+	}
+
+	reader, err := maxminddb.Open(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client networks: open %s database: %w", name, err)
+	}
+
+	values := make(map[T]bool, len(cfg.Values))
+	for _, v := range cfg.Values {
+		values[v] = true
+	}
+	return reader, values, nil
+}
+
+// Close releases the databases.
+func (c *clientNetworks) Close() error {
+	var errs []error
+	if c.country != nil {
+		errs = append(errs, c.country.Close())
+	}
+	if c.asn != nil {
+		errs = append(errs, c.asn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// value returns the value of dimension for addr. It returns false when the source of the dimension
+// is not configured.
+func (c *clientNetworks) value(dimension string, addr netip.Addr) (string, bool) {
+	switch dimension {
+	case metricDimensionCountry:
+		if c.country == nil {
+			return "", false
+		}
+
+		var code string
+		if addr.IsValid() {
+			if err := c.country.Lookup(addr).DecodePath(&code, "country", "iso_code"); err != nil {
+				code = ""
+			}
+		}
+		if !c.countries[code] {
+			return otherFieldValue, true
+		}
+		return code, true
+
+	case metricDimensionASN:
+		if c.asn == nil {
+			return "", false
+		}
+
+		var number uint32
+		if addr.IsValid() {
+			if err := c.asn.Lookup(addr).DecodePath(&number, "autonomous_system_number"); err != nil {
+				number = 0
+			}
+		}
+		if !c.asns[number] {
+			return otherFieldValue, true
+		}
+		return strconv.FormatUint(uint64(number), 10), true
+
+	case metricDimensionNetwork:
+		if len(c.networks) == 0 {
+			return "", false
+		}
+
+		for _, network := range c.networks {
+			if network.prefix.Contains(addr) {
+				return network.label, true
+			}
+		}
+		return otherFieldValue, true
+	}
+
+	return "", false
+}
+
+// validateNetworkDimensions checks a rule's network_dimensions.
+func validateNetworkDimensions(dimensions []string) error {
+	for idx, dimension := range dimensions {
+		if !slices.Contains(networkDimensions, dimension) {
+			return fmt.Errorf("network dimension %d: unknown dimension %q, expected one of %s", idx, dimension, strings.Join(networkDimensions, ", "))
+		}
+		if slices.Contains(dimensions[:idx], dimension) {
+			return fmt.Errorf("network dimension %d: %s is listed more than once", idx, dimension)
+		}
+	}
+	return nil
+}
+
+// ClientNetworks exposes the client network lookups for configuration.
+type ClientNetworks = clientNetworks
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestDatabase writes a MaxMind database holding a record per CIDR and returns its path.
+func writeTestDatabase(t *testing.T, databaseType string, records map[string]mmdbtype.Map) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: databaseType, IncludeReservedNetworks: true})
+	require.NoError(t, err)
+
+	for cidr, record := range records {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, record))
+	}
+
+	path := filepath.Join(t.TempDir(), databaseType+".mmdb")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+	return path
+}
+
+// testClientNetworks returns a CLIENT_NETWORKS configuration backed by test databases.
+func testClientNetworks(t *testing.T) string {
+	t.Helper()
+
+	country := writeTestDatabase(t, "GeoLite2-Country", map[string]mmdbtype.Map{
+		"203.0.113.0/24":  {"country": mmdbtype.Map{"iso_code": mmdbtype.String("JP")}},
+		"198.51.100.0/24": {"country": mmdbtype.Map{"iso_code": mmdbtype.String("US")}},
+		"192.0.2.0/24":    {"country": mmdbtype.Map{"iso_code": mmdbtype.String("DE")}},
+		"2001:db8::/32":   {"country": mmdbtype.Map{"iso_code": mmdbtype.String("JP")}},
+	})
+	asn := writeTestDatabase(t, "GeoLite2-ASN", map[string]mmdbtype.Map{
+		"203.0.113.0/24":  {"autonomous_system_number": mmdbtype.Uint32(2516)},
+		"198.51.100.0/24": {"autonomous_system_number": mmdbtype.Uint32(16509)},
+	})
+
+	return fmt.Sprintf(`{
+		"country": {"database": %q, "values": ["JP", "US"]},
+		"asn": {"database": %q, "values": [2516]},
+		"networks": [{"cidr": "10.0.0.0/8", "label": "internal"}, {"cidr": "198.51.100.64/26", "label": "office"}]
+	}`, country, asn)
+}
+
+func TestClientNetworks_Value(t *testing.T) {
+	networks, err := NewClientNetworks(testClientNetworks(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, networks.Close()) })
+
+	tests := []struct {
+		addr      string
+		dimension string
+		want      string
+	}{
+		{addr: "203.0.113.7", dimension: metricDimensionCountry, want: "JP"},
+		{addr: "2001:db8::1", dimension: metricDimensionCountry, want: "JP"},
+		{addr: "198.51.100.7", dimension: metricDimensionCountry, want: "US"},
+		{addr: "192.0.2.7", dimension: metricDimensionCountry, want: otherFieldValue},
+		{addr: "10.1.2.3", dimension: metricDimensionCountry, want: otherFieldValue},
+		{addr: "203.0.113.7", dimension: metricDimensionASN, want: "2516"},
+		{addr: "198.51.100.7", dimension: metricDimensionASN, want: otherFieldValue},
+		{addr: "10.1.2.3", dimension: metricDimensionNetwork, want: "internal"},
+		{addr: "198.51.100.70", dimension: metricDimensionNetwork, want: "office"},
+		{addr: "198.51.100.7", dimension: metricDimensionNetwork, want: otherFieldValue},
+		{dimension: metricDimensionCountry, want: otherFieldValue},
+	}
+
+	for _, tt := range tests {
+		var addr netip.Addr
+		if tt.addr != "" {
+			addr = netip.MustParseAddr(tt.addr)
+		}
+
+		got, ok := networks.value(tt.dimension, addr)
+		assert.True(t, ok)
+		assert.Equal(t, tt.want, got, "%s %s", tt.dimension, tt.addr)
+	}
+}
+
+func TestNewClientNetworks(t *testing.T) {
+	networks, err := NewClientNetworks("")
+	require.NoError(t, err)
+	assert.Nil(t, networks)
+
+	networks, err = NewClientNetworks(`{"networks":[{"cidr":"10.0.0.0/8","label":"internal"}]}`)
+	require.NoError(t, err)
+	_, ok := networks.value(metricDimensionCountry, netip.MustParseAddr("10.0.0.1"))
+	assert.False(t, ok, "Country is not configured")
+
+	tests := map[string]struct {
+		raw  string
+		want string
+	}{
+		"invalid JSON":     {raw: `{`, want: "failed to parse client networks JSON"},
+		"empty":            {raw: `{}`, want: "country, asn or networks is required"},
+		"missing database": {raw: `{"country":{"values":["JP"]}}`, want: "country database is required"},
+		"missing values":   {raw: `{"asn":{"database":"asn.mmdb"}}`, want: "asn values must not be empty"},
+		"missing file":     {raw: `{"country":{"database":"missing.mmdb","values":["JP"]}}`, want: "open country database"},
+		"invalid cidr":     {raw: `{"networks":[{"cidr":"10.0.0.0","label":"internal"}]}`, want: "client network 0: failed to parse cidr"},
+		"missing label":    {raw: `{"networks":[{"cidr":"10.0.0.0/8"}]}`, want: "client network 0: label is required"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewClientNetworks(tt.raw)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	_, err = NewPathRules(`[{"host":"example.com","pattern":"^/$","name":"/","network_dimensions":["City"]}]`)
+	assert.ErrorContains(t, err, `path rule 0: network dimension 0: unknown dimension "City"`)
+}
+
+func TestProcessLines_NetworkDimensions(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/search$","name":"/search","network_dimensions":["Country","Network"],"metrics":["RequestCount"]}
+	]`)
+	require.NoError(t, err)
+
+	networks, err := NewClientNetworks(testClientNetworks(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, networks.Close()) })
+
+	var lines []string
+	for _, client := range []string{"203.0.113.7", "203.0.113.8", "198.51.100.70", "10.0.0.1"} {
+		fields := testFields("GET", "example.com", "/search")
+		fields.ClientIP = client
+		lines = append(lines, fields.String())
+	}
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, ClientNetworks: networks})
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	got := make(map[string]float64)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		key := aws.ToString(datum.Dimensions[2].Value)
+		if len(datum.Dimensions) == 4 {
+			key += " " + aws.ToString(datum.Dimensions[3].Name) + "=" + aws.ToString(datum.Dimensions[3].Value)
+		}
+		got[key] = aws.ToFloat64(datum.Value)
+	}
+
+	assert.Equal(t, map[string]float64{
+		"/search":                  4,
+		"/search Country=JP":       2,
+		"/search Country=US":       1,
+		"/search Country=Other":    1,
+		"/search Network=office":   1,
+		"/search Network=internal": 1,
+		"/search Network=Other":    2,
+	}, got)
+}
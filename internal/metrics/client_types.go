@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	metricDimensionClientType = "ClientType"
+
+	// defaultClientType is the label of user agents no client type rule matches, unless configured.
+	defaultClientType = "Other"
+
+	// maxClientTypeCache bounds the number of user agents whose label clientClassifier remembers.
+	maxClientTypeCache = 10000
+)
+
+// clientClassifierConfig represents the JSON shape used to configure client types.
+type clientClassifierConfig struct {
+	Rules []clientTypeRuleConfig `json:"rules"`
+	// Default labels user agents that no rule matches.
+	Default string `json:"default,omitempty"`
+}
+
+type clientTypeRuleConfig struct {
+	Pattern string `json:"pattern"`
+	Label   string `json:"label"`
+}
+
+type clientTypeRule struct {
+	regex *regexp.Regexp
+	label string
+}
+
+// clientClassifier labels requests by their user agent with the first matching rule.
+type clientClassifier struct {
+	rules        []clientTypeRule
+	defaultLabel string
+	// cache holds the label of recently seen user agents, since most log lines repeat a few of them.
+	cache map[string]string
+}
+
+// NewClientClassifier parses the JSON configuration string. It returns nil when raw is empty.
+func NewClientClassifier(raw string) (*clientClassifier, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var cfg clientClassifierConfig
+	if err := json.Unmarshal([]byte(trimmed), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse client types JSON: %w", err)
+	}
+
+	if len(cfg.Rules) == 0 {
+		return nil, fmt.Errorf("client types: rules must not be empty")
+	}
+
+	c := &clientClassifier{
+		rules:        make([]clientTypeRule, 0, len(cfg.Rules)),
+		defaultLabel: cfg.Default,
+		cache:        make(map[string]string),
+	}
+	if c.defaultLabel == "" {
+		c.defaultLabel = defaultClientType
+	}
+
+	for idx, rule := range cfg.Rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("client type rule %d: pattern is required", idx)
+		}
+
+		if rule.Label == "" {
+			return nil, fmt.Errorf("client type rule %d: label is required", idx)
+		}
+
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("client type rule %d: failed to compile pattern regex: %w", idx, err)
+		}
+
+		c.rules = append(c.rules, clientTypeRule{regex: regex, label: rule.Label})
+	}
+
+	return c, nil
+}
+
+// classify returns the label of the first rule matching userAgent, or the default label.
+func (c *clientClassifier) classify(userAgent string) string {
+	if label, ok := c.cache[userAgent]; ok {
+		return label
+	}
+
+	label := c.defaultLabel
+	for _, rule := range c.rules {
+		if rule.regex.MatchString(userAgent) {
+			label = rule.label
+			break
+		}
+	}
+
+	if len(c.cache) < maxClientTypeCache {
+		c.cache[userAgent] = label
+	}
+	return label
+}
+
+// ClientClassifier exposes the client type classifier for configuration.
+type ClientClassifier = clientClassifier
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientTypes = `{"rules":[
+	{"pattern":"^MyApp/[0-9.]+ \\((iOS|Android)","label":"mobile"},
+	{"pattern":"^partner-sdk/","label":"partner"},
+	{"pattern":"Mozilla/","label":"web"}
+],"default":"unknown"}`
+
+func TestClientClassifier_Classify(t *testing.T) {
+	classifier, err := NewClientClassifier(testClientTypes)
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"MyApp/4.2.1 (iOS 17.1; iPhone14,2)":            "mobile",
+		"MyApp/4.2.1 (Android 14; Pixel 8)":             "mobile",
+		"partner-sdk/1.0 Mozilla/5.0":                   "partner",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15)": "web",
+		"curl/8.4.0": "unknown",
+		"-":          "unknown",
+	}
+
+	for userAgent, want := range tests {
+		assert.Equal(t, want, classifier.classify(userAgent), userAgent)
+		// The cached label must match the computed one.
+		assert.Equal(t, want, classifier.classify(userAgent), userAgent)
+	}
+}
+
+func TestNewClientClassifier(t *testing.T) {
+	classifier, err := NewClientClassifier("")
+	require.NoError(t, err)
+	assert.Nil(t, classifier)
+
+	classifier, err = NewClientClassifier(`{"rules":[{"pattern":"^MyApp/","label":"mobile"}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, defaultClientType, classifier.classify("curl/8.4.0"))
+
+	tests := map[string]struct {
+		raw  string
+		want string
+	}{
+		"invalid JSON":    {raw: `{`, want: "failed to parse client types JSON"},
+		"no rules":        {raw: `{"default":"web"}`, want: "rules must not be empty"},
+		"missing pattern": {raw: `{"rules":[{"label":"mobile"}]}`, want: "client type rule 0: pattern is required"},
+		"missing label":   {raw: `{"rules":[{"pattern":"^MyApp/"}]}`, want: "client type rule 0: label is required"},
+		"invalid pattern": {raw: `{"rules":[{"pattern":"(","label":"mobile"}]}`, want: "client type rule 0: failed to compile pattern regex"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewClientClassifier(tt.raw)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestProcessLines_ClientType(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/checkout$","name":"/checkout","client_type":true,"metrics":["RequestCount"]},
+		{"host":"example.com","pattern":"^/users$","name":"/users","metrics":["RequestCount"]}
+	]`)
+	require.NoError(t, err)
+
+	classifier, err := NewClientClassifier(testClientTypes)
+	require.NoError(t, err)
+
+	mobile := testFields("POST", "example.com", "/checkout")
+	mobile.UserAgent = "MyApp/4.2.1 (iOS 17.1; iPhone14,2)"
+
+	web := testFields("POST", "example.com", "/checkout")
+
+	partner := testFields("POST", "example.com", "/checkout")
+	partner.UserAgent = "partner-sdk/2.3"
+
+	users := testFields("GET", "example.com", "/users")
+
+	lines := []string{mobile.String(), web.String(), web.String(), partner.String(), users.String()}
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, ClientTypes: classifier})
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	got := make(map[string]float64)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		key := aws.ToString(datum.Dimensions[2].Value)
+		if len(datum.Dimensions) == 4 {
+			require.Equal(t, metricDimensionClientType, aws.ToString(datum.Dimensions[3].Name))
+			key += " " + aws.ToString(datum.Dimensions[3].Value)
+		}
+		got[key] = aws.ToFloat64(datum.Value)
+	}
+
+	assert.Equal(t, map[string]float64{
+		"/checkout":         4,
+		"/checkout mobile":  1,
+		"/checkout web":     2,
+		"/checkout partner": 1,
+		"/users":            1,
+	}, got)
+}
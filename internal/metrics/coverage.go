@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxUnmatchedTemplates bounds the memory used to track distinct unmatched paths.
+	maxUnmatchedTemplates = 10000
+
+	// unmatchedOverflowTemplate collects unmatched requests once maxUnmatchedTemplates is reached.
+	unmatchedOverflowTemplate = "(other)"
+)
+
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	numSegment  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+type unmatchedKey struct {
+	Method   string
+	Host     string
+	Template string
+}
+
+type hostCoverage struct {
+	matched   int
+	unmatched int
+}
+
+// coverageTracker counts how much of the parsed traffic the rule set matches.
+type coverageTracker struct {
+	hosts       map[string]*hostCoverage
+	unmatched   map[unmatchedKey]int
+	ruleMatches []int
+}
+
+func newCoverageTracker(rules *pathRules) *coverageTracker {
+	var ruleCount int
+	if rules != nil {
+		ruleCount = len(rules.rules)
+	}
+
+	return &coverageTracker{
+		hosts:       make(map[string]*hostCoverage),
+		unmatched:   make(map[unmatchedKey]int),
+		ruleMatches: make([]int, ruleCount),
+	}
+}
+
+// record counts a parsed entry against the rule at ruleIdx, or as unmatched when matched is false.
+func (c *coverageTracker) record(entry albLogEntry, ruleIdx int, matched bool) {
+	host, ok := c.hosts[entry.host]
+	if !ok {
+		host = &hostCoverage{}
+		c.hosts[entry.host] = host
+	}
+
+	if matched {
+		host.matched++
+		c.ruleMatches[ruleIdx]++
+		return
+	}
+
+	host.unmatched++
+
+	key := unmatchedKey{Method: entry.method, Host: entry.host, Template: pathTemplate(entry.path)}
+	if _, ok := c.unmatched[key]; !ok && len(c.unmatched) >= maxUnmatchedTemplates {
+		key = unmatchedKey{Template: unmatchedOverflowTemplate}
+	}
+	c.unmatched[key]++
+}
+
+// pathTemplate collapses path segments that look like identifiers so similar paths group together.
+func pathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+		case numSegment.MatchString(segment):
+			segments[i] = ":num"
+		case uuidSegment.MatchString(segment):
+			segments[i] = ":uuid"
+		case hexSegment.MatchString(segment):
+			segments[i] = ":hex"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// CoverageReport summarizes how much traffic the rule set matched.
+type CoverageReport struct {
+	Hosts        []HostCoverage  `json:"hosts"`
+	TopUnmatched []UnmatchedPath `json:"top_unmatched"`
+	Rules        []RuleCoverage  `json:"rules"`
+}
+
+// HostCoverage holds matched and unmatched line counts for a single host.
+type HostCoverage struct {
+	Host      string  `json:"host"`
+	Matched   int     `json:"matched"`
+	Unmatched int     `json:"unmatched"`
+	Coverage  float64 `json:"coverage"`
+}
+
+// UnmatchedPath is a method and path template that no rule matched.
+type UnmatchedPath struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+	Count  int    `json:"count"`
+}
+
+// RuleCoverage holds the number of lines a single rule matched.
+type RuleCoverage struct {
+	Rule    int    `json:"rule"`
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	Method  string `json:"method,omitempty"`
+	Matched int    `json:"matched"`
+}
+
+// report builds a sorted CoverageReport listing at most topN unmatched path templates.
+func (c *coverageTracker) report(rules *pathRules, topN int) CoverageReport {
+	report := CoverageReport{
+		Hosts:        make([]HostCoverage, 0, len(c.hosts)),
+		TopUnmatched: make([]UnmatchedPath, 0, len(c.unmatched)),
+		Rules:        make([]RuleCoverage, 0, len(c.ruleMatches)),
+	}
+
+	for host, counts := range c.hosts {
+		report.Hosts = append(report.Hosts, HostCoverage{
+			Host:      host,
+			Matched:   counts.matched,
+			Unmatched: counts.unmatched,
+			Coverage:  float64(counts.matched) / float64(counts.matched+counts.unmatched),
+		})
+	}
+	slices.SortFunc(report.Hosts, func(a, b HostCoverage) int {
+		return strings.Compare(a.Host, b.Host)
+	})
+
+	for key, count := range c.unmatched {
+		report.TopUnmatched = append(report.TopUnmatched, UnmatchedPath{
+			Method: key.Method,
+			Host:   key.Host,
+			Path:   key.Template,
+			Count:  count,
+		})
+	}
+	slices.SortFunc(report.TopUnmatched, func(a, b UnmatchedPath) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			strings.Compare(a.Host, b.Host),
+			strings.Compare(a.Path, b.Path),
+			strings.Compare(a.Method, b.Method),
+		)
+	})
+	if topN >= 0 && len(report.TopUnmatched) > topN {
+		report.TopUnmatched = report.TopUnmatched[:topN]
+	}
+
+	for idx, count := range c.ruleMatches {
+		rule := rules.rules[idx]
+		report.Rules = append(report.Rules, RuleCoverage{
+			Rule:    idx,
+			Name:    rule.name,
+			Host:    rule.host,
+			Method:  rule.method,
+			Matched: count,
+		})
+	}
+
+	return report
+}
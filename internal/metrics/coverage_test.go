@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathTemplate(t *testing.T) {
+	tests := map[string]string{
+		"/":                 "/",
+		"/users/123":        "/users/:num",
+		"/users/123/posts/": "/users/:num/posts/",
+		"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301": "/orders/:uuid",
+		"/blobs/0123456789abcdef0123":                  "/blobs/:hex",
+		"/articles/hello-world":                        "/articles/hello-world",
+	}
+
+	for path, want := range tests {
+		assert.Equal(t, want, pathTemplate(path), path)
+	}
+}
+
+func TestProcessor_CoverageReport(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"api.example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"api.example.com","pattern":"^/orders$","name":"/orders"}
+	]`)
+	require.NoError(t, err)
+
+	processor := NewProcwessor(nil, nil, rules, ProcessorOptions{Coverage: true, PublishUnmatchedCount: true})
+
+	lines := []string{
+		testLogLine("GET", "http://api.example.com/users/1"),
+		testLogLine("GET", "http://api.example.com/users/2"),
+		testLogLine("POST", "http://api.example.com/users/3"),
+		testLogLine("GET", "http://api.example.com/health"),
+		testLogLine("GET", "http://api.example.com/health"),
+		testLogLine("GET", "http://www.example.com/"),
+		"invalid",
+	}
+	require.NoError(t, processor.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	report, err := processor.CoverageReport(2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []HostCoverage{
+		{Host: "api.example.com", Matched: 2, Unmatched: 3, Coverage: 0.4},
+		{Host: "www.example.com", Matched: 0, Unmatched: 1, Coverage: 0},
+	}, report.Hosts)
+	assert.Equal(t, []UnmatchedPath{
+		{Method: "GET", Host: "api.example.com", Path: "/health", Count: 2},
+		{Method: "POST", Host: "api.example.com", Path: "/users/:num", Count: 1},
+	}, report.TopUnmatched)
+	assert.Equal(t, []RuleCoverage{
+		{Rule: 0, Name: "/users/:id", Host: "api.example.com", Method: "GET", Matched: 2},
+		{Rule: 1, Name: "/orders", Host: "api.example.com", Matched: 0},
+	}, report.Rules)
+
+	unmatched := make(map[string]float64)
+	for _, datum := range processor.aggregator.GetCloudWatchMetricData() {
+		if *datum.MetricName == metricNameUnmatchedRequestCount {
+			unmatched[*datum.Dimensions[0].Value] = *datum.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{"api.example.com": 3, "www.example.com": 1}, unmatched)
+}
+
+func TestProcessor_CoverageReportDisabled(t *testing.T) {
+	processor := NewProcwessor(nil, nil, &PathRules{}, ProcessorOptions{})
+
+	_, err := processor.CoverageReport(10)
+	assert.Error(t, err)
+}
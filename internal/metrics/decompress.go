@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingGzip  = "gzip"
+	encodingZstd  = "zstd"
+	encodingBzip2 = "bzip2"
+	encodingPlain = "plain"
+
+	// albTestFileName is the object ALB writes when access logging is first enabled.
+	albTestFileName = "ELBAccessLogTestFile"
+
+	// albSniffLines is how many leading lines are read for one that looks like an ALB log before the
+	// object is skipped.
+	albSniffLines = 5
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+
+	// errNotALBLog is returned when an object's content does not look like an ALB access log.
+	errNotALBLog = errors.New("content does not look like an ALB access log")
+
+	// albRequestTypes lists the values ALB writes in the first field of each log line.
+	albRequestTypes = map[string]bool{
+		"http":  true,
+		"https": true,
+		"h2":    true,
+		"grpcs": true,
+		"ws":    true,
+		"wss":   true,
+	}
+)
+
+// NewLogReader sniffs the leading bytes of r and returns a reader over the decompressed log lines.
+// gzip (including multi-member streams), zstd and bzip2 are decompressed; anything else is read as plain text.
+func NewLogReader(r io.Reader) (io.ReadCloser, error) {
+	rc, _, err := newLogReader(r)
+	return rc, err
+}
+
+func newLogReader(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", fmt.Errorf("read object header: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		gzipReader, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, encodingGzip, fmt.Errorf("create gzip reader: %w", err)
+		}
+		return gzipReader, encodingGzip, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zstdReader, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, encodingZstd, fmt.Errorf("create zstd reader: %w", err)
+		}
+		return zstdReader.IOReadCloser(), encodingZstd, nil
+	case bytes.HasPrefix(head, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(br)), encodingBzip2, nil
+	default:
+		return io.NopCloser(br), encodingPlain, nil
+	}
+}
+
+// looksLikeALBLog reports whether the line starts with an ALB request type field.
+func looksLikeALBLog(line string) bool {
+	typ, _, found := strings.Cut(line, " ")
+	return found && albRequestTypes[typ]
+}
+
+// skipObjectReason returns why the object key should not be processed, or an empty string.
+func skipObjectReason(key, prefix string) string {
+	if path.Base(key) == albTestFileName {
+		return "ALB access log test file"
+	}
+
+	if prefix != "" && !strings.HasPrefix(key, prefix) {
+		return "key outside prefix " + prefix
+	}
+
+	return ""
+}
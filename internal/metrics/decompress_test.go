@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestNewLogReader_Encodings(t *testing.T) {
+	bzip2Fixture, err := os.ReadFile("testdata/generated.log.bz2")
+	require.NoError(t, err)
+
+	// The bzip2 fixture holds the first 20 lines of the gzip fixture.
+	gzipFixture, err := os.Open("testdata/generated.log.gz")
+	require.NoError(t, err)
+	defer gzipFixture.Close()
+	gz, err := gzip.NewReader(gzipFixture)
+	require.NoError(t, err)
+	var firstLines strings.Builder
+	scanner := bufio.NewScanner(gz)
+	for i := 0; i < 20 && scanner.Scan(); i++ {
+		firstLines.WriteString(scanner.Text() + "\n")
+	}
+
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	require.NoError(t, err)
+	_, err = zw.Write([]byte("line 1\nline 2\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	multiMember := append(gzipBytes(t, "line 1\n"), gzipBytes(t, "line 2\n")...)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		want     string
+	}{
+		{name: "gzip multi-member", body: multiMember, encoding: encodingGzip, want: "line 1\nline 2\n"},
+		{name: "zstd", body: zstdBuf.Bytes(), encoding: encodingZstd, want: "line 1\nline 2\n"},
+		{name: "plain", body: []byte("line 1\nline 2\n"), encoding: encodingPlain, want: "line 1\nline 2\n"},
+		{name: "empty", body: nil, encoding: encodingPlain, want: ""},
+		{name: "bzip2", body: bzip2Fixture, encoding: encodingBzip2, want: firstLines.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, encoding, err := newLogReader(bytes.NewReader(tt.body))
+			require.NoError(t, err)
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.encoding, encoding)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestLooksLikeALBLog(t *testing.T) {
+	assert.True(t, looksLikeALBLog(testLogLine("GET", "http://example.com/")))
+	assert.True(t, looksLikeALBLog("h2 2024-01-15T10:00:00.000000Z app/lb"))
+	assert.False(t, looksLikeALBLog("Enable AccessLog for ELB: app/prod-alb"))
+	assert.False(t, looksLikeALBLog("https"))
+}
+
+func TestSkipObjectReason(t *testing.T) {
+	assert.NotEmpty(t, skipObjectReason("AWSLogs/123456789012/ELBAccessLogTestFile", ""))
+	assert.NotEmpty(t, skipObjectReason("tmp/a.log.gz", "AWSLogs/"))
+	assert.Empty(t, skipObjectReason("AWSLogs/123456789012/a.log.gz", "AWSLogs/"))
+	assert.Empty(t, skipObjectReason("a.log.gz", ""))
+}
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/albgen"
+)
+
+const generatedFixtureRules = `[
+	{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+	{"host":"admin.example.com","pattern":"^/dashboard$","name":"/dashboard"}
+]`
+
+// countFixtureRequests counts fixture lines whose request field contains the given URL.
+func countFixtureRequests(t *testing.T, name, rawURL string) int {
+	t.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	var count int
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), `"GET `+rawURL+` HTTP/1.1"`) {
+			count++
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return count
+}
+
+// sumByPath totals the metric per Path dimension, using Counts for distribution metrics.
+func sumByPath(data []types.MetricDatum, metricName string) map[string]float64 {
+	sums := make(map[string]float64)
+	for _, datum := range data {
+		if aws.ToString(datum.MetricName) != metricName {
+			continue
+		}
+
+		var path string
+		for _, d := range datum.Dimensions {
+			if aws.ToString(d.Name) == metricDimensionPath {
+				path = aws.ToString(d.Value)
+			}
+		}
+
+		if datum.Value != nil {
+			sums[path] += aws.ToFloat64(datum.Value)
+		}
+		for _, c := range datum.Counts {
+			sums[path] += c
+		}
+	}
+	return sums
+}
+
+func TestHandleEvent_GeneratedFixture(t *testing.T) {
+	h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{})
+	h.putFixture("logs", "AWSLogs/123456789012/elasticloadbalancing/generated.log.gz", "generated.log.gz")
+
+	err := h.processor.HandleEvent(context.Background(), h.event("logs", "AWSLogs/123456789012/elasticloadbalancing/generated.log.gz"))
+	require.NoError(t, err)
+
+	users := float64(countFixtureRequests(t, "generated.log.gz", "https://example.com:443/users/123"))
+	dashboard := float64(countFixtureRequests(t, "generated.log.gz", "https://admin.example.com:443/dashboard"))
+	require.NotZero(t, users)
+	require.NotZero(t, dashboard)
+
+	for _, input := range h.putter.inputs {
+		assert.Equal(t, "ALBAccessLog", aws.ToString(input.Namespace))
+		assert.LessOrEqual(t, len(input.MetricData), defaultMetricBatchSize)
+	}
+
+	data := h.putter.datums("ALBAccessLog")
+	assert.Equal(t, map[string]float64{"/users/:id": users, "/dashboard": dashboard}, sumByPath(data, metricNameRequestCount))
+	assert.Equal(t, map[string]float64{"/users/:id": 0, "/dashboard": 0}, sumByPath(data, metricNameFailedRequestCount))
+	assert.Equal(t, map[string]float64{"/users/:id": users, "/dashboard": dashboard}, sumByPath(data, metricNameTargetResponseTime))
+}
+
+func TestHandleEvent_MultipleObjectsAndPipelineMetrics(t *testing.T) {
+	h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{PipelineNamespace: "ALBAccessLog/Pipeline"})
+	h.putFixture("logs", "a.log.gz", "generated.log.gz")
+	h.putLines("logs", "b.log.gz",
+		testLogLine("GET", "http://example.com/users/1"),
+		testLogLine("GET", "http://example.com/users/2"),
+	)
+
+	err := h.processor.HandleEvent(context.Background(), h.event("logs", "a.log.gz", "b.log.gz"))
+	require.NoError(t, err)
+
+	users := float64(countFixtureRequests(t, "generated.log.gz", "https://example.com:443/users/123"))
+	assert.Equal(t, users+2, sumByPath(h.putter.datums("ALBAccessLog"), metricNameRequestCount)["/users/:id"])
+
+	pipeline := make(map[string]float64)
+	for _, datum := range h.putter.datums("ALBAccessLog/Pipeline") {
+		pipeline[aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+	assert.Equal(t, 2.0, pipeline[metricNameObjectsProcessed])
+	assert.Equal(t, 502.0, pipeline[metricNameLinesRead])
+	assert.Equal(t, float64(len(h.putter.datums("ALBAccessLog"))), pipeline[metricNameDatumsPublished])
+	assert.Equal(t, 0.0, pipeline[metricNamePutMetricDataFailures])
+}
+
+func TestHandleEvent_ParseErrorThreshold(t *testing.T) {
+	h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{ParseErrorThreshold: 0.2})
+	h.putLines("logs", "bad.log.gz",
+		testLogLine("GET", "http://example.com/users/1"),
+		"truncated line",
+	)
+
+	err := h.processor.HandleEvent(context.Background(), h.event("logs", "bad.log.gz"))
+
+	require.Error(t, err)
+	// A retry publishes the object's metrics and parse errors, so the failed attempt publishes neither.
+	assert.Empty(t, h.putter.datums("ALBAccessLog"))
+}
+
+func TestHandleEvent_Errors(t *testing.T) {
+	t.Run("get object", func(t *testing.T) {
+		h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{})
+		h.getter.err = errors.New("access denied")
+
+		err := h.processor.HandleEvent(context.Background(), h.event("logs", "a.log.gz"))
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("corrupt gzip header", func(t *testing.T) {
+		h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{})
+		h.getter.objects["logs/a.log.gz"] = []byte{0x1f, 0x8b, 0x00, 0x00}
+
+		err := h.processor.HandleEvent(context.Background(), h.event("logs", "a.log.gz"))
+		assert.Error(t, err)
+	})
+
+	t.Run("put metric data", func(t *testing.T) {
+		h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{})
+		h.putFixture("logs", "a.log.gz", "generated.log.gz")
+		h.putter.err = errors.New("throttled")
+
+		err := h.processor.HandleEvent(context.Background(), h.event("logs", "a.log.gz"))
+		assert.ErrorContains(t, err, "throttled")
+	})
+
+	t.Run("missing bucket", func(t *testing.T) {
+		h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{})
+
+		err := h.processor.HandleEvent(context.Background(), h.event("", "a.log.gz"))
+		assert.Error(t, err)
+	})
+}
+
+func TestHandleEvent_SkipsNonLogObjects(t *testing.T) {
+	h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{
+		KeyPrefix:         "AWSLogs/",
+		PipelineNamespace: "ALBAccessLog/Pipeline",
+	})
+	h.putLines("logs", "AWSLogs/123456789012/ELBAccessLogTestFile", "Enable AccessLog for ELB: app/prod-alb")
+	h.putLines("logs", "AWSLogs/123456789012/notes.txt.gz", "not an access log")
+	h.putLines("logs", "other/a.log.gz", testLogLine("GET", "http://example.com/users/1"))
+	h.putLines("logs", "AWSLogs/a.log.gz", testLogLine("GET", "http://example.com/users/1"))
+
+	err := h.processor.HandleEvent(context.Background(), h.event("logs",
+		"AWSLogs/123456789012/ELBAccessLogTestFile",
+		"AWSLogs/123456789012/notes.txt.gz",
+		"other/a.log.gz",
+		"AWSLogs/a.log.gz",
+	))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{"/users/:id": 1}, sumByPath(h.putter.datums("ALBAccessLog"), metricNameRequestCount))
+
+	pipeline := make(map[string]float64)
+	for _, datum := range h.putter.datums("ALBAccessLog/Pipeline") {
+		pipeline[aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+	assert.Equal(t, 1.0, pipeline[metricNameObjectsProcessed])
+	assert.Equal(t, 3.0, pipeline[metricNameObjectsSkipped])
+}
+
+func TestHandleEvent_CorruptFirstLine(t *testing.T) {
+	h := newTestHarness(t, generatedFixtureRules, ProcessorOptions{PipelineNamespace: "ALBAccessLog/Pipeline"})
+	h.putLines("logs", "a.log.gz",
+		"\x00\x00garbage",
+		testLogLine("GET", "http://example.com/users/1"),
+		testLogLine("GET", "http://example.com/users/2"),
+	)
+
+	err := h.processor.HandleEvent(context.Background(), h.event("logs", "a.log.gz"))
+	require.NoError(t, err)
+
+	data := h.putter.datums("ALBAccessLog")
+	assert.Equal(t, map[string]float64{"/users/:id": 2}, sumByPath(data, metricNameRequestCount))
+
+	var parseErrors float64
+	for _, datum := range data {
+		if aws.ToString(datum.MetricName) == metricNameParseErrorCount {
+			parseErrors += aws.ToFloat64(datum.Value)
+		}
+	}
+	assert.Equal(t, 1.0, parseErrors)
+
+	pipeline := make(map[string]float64)
+	for _, datum := range h.putter.datums("ALBAccessLog/Pipeline") {
+		pipeline[aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+	assert.Equal(t, 1.0, pipeline[metricNameObjectsProcessed])
+	assert.Equal(t, 0.0, pipeline[metricNameObjectsSkipped])
+	assert.Equal(t, 3.0, pipeline[metricNameLinesRead])
+}
+
+func TestHandleEvent_ScenarioMatchesExpectedMetrics(t *testing.T) {
+	rules, err := os.ReadFile("testdata/scenario/rules.json")
+	require.NoError(t, err)
+	expected, err := albgen.LoadExpectedMetrics("testdata/scenario/expected.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, expected.Metrics)
+
+	h := newTestHarness(t, string(rules), ProcessorOptions{})
+
+	var keys []string
+	root := "testdata/scenario/logs"
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		key, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		h.putFixture("logs", key, "scenario/logs/"+key)
+		keys = append(keys, key)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, keys)
+
+	require.NoError(t, h.processor.HandleEvent(context.Background(), h.event("logs", keys...)))
+
+	assertExpectedMetrics(t, expected, h.putter.datums("ALBAccessLog"))
+}
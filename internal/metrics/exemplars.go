@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Kinds of exemplars.
+const (
+	exemplarKindSlowest = "slowest"
+	exemplarKindFailed  = "failed"
+)
+
+// exemplar is an example request kept to find the trace behind a metric.
+type exemplar struct {
+	TraceID   string
+	Timestamp time.Time
+	Status    int
+	// Latency is the target processing time in seconds, -1 when no target responded.
+	Latency float64
+	Target  string
+}
+
+// exemplarSet holds the exemplars of one Method/Host/Path and minute.
+type exemplarSet struct {
+	// slowest is ordered from the slowest request.
+	slowest []exemplar
+	// failed is a uniform sample of the failedSeen failed requests.
+	failed     []exemplar
+	failedSeen int
+}
+
+// exemplarRecorder keeps the slowest requests and a sample of failed requests per Method/Host/Path and minute.
+type exemplarRecorder struct {
+	limit int
+	sets  map[metricKey]*exemplarSet
+	// intN returns a random number in [0, n); it is replaced in tests.
+	intN func(n int) int
+}
+
+func newExemplarRecorder(limit int) *exemplarRecorder {
+	return &exemplarRecorder{limit: limit, sets: make(map[metricKey]*exemplarSet), intN: rand.IntN}
+}
+
+// record considers the entry read from line as an exemplar of the rule name. The trace ID is only
+// read from line when the entry is kept.
+func (r *exemplarRecorder) record(entry albLogEntry, name string, failed bool, line []byte) {
+	minute := entry.timestamp.UTC().Truncate(time.Minute)
+	key := metricKey{Method: entry.method, Host: entry.host, Path: name, Minute: minute}
+
+	set, ok := r.sets[key]
+	if !ok {
+		set = &exemplarSet{}
+		r.sets[key] = set
+	}
+
+	if entry.targetProcessingTime >= 0 {
+		// Keep slowest ordered; once it is full, requests faster than every kept one are dropped.
+		idx, _ := slices.BinarySearchFunc(set.slowest, entry.targetProcessingTime, func(e exemplar, latency float64) int {
+			return cmp.Compare(latency, e.Latency)
+		})
+		if idx < r.limit {
+			if len(set.slowest) == r.limit {
+				set.slowest = set.slowest[:r.limit-1]
+			}
+			set.slowest = slices.Insert(set.slowest, idx, newExemplar(entry, line))
+		}
+	}
+
+	if failed {
+		// Reservoir sampling keeps every failed request equally likely to be an exemplar.
+		set.failedSeen++
+		if len(set.failed) < r.limit {
+			set.failed = append(set.failed, newExemplar(entry, line))
+		} else if j := r.intN(set.failedSeen); j < r.limit {
+			set.failed[j] = newExemplar(entry, line)
+		}
+	}
+}
+
+func newExemplar(entry albLogEntry, line []byte) exemplar {
+	return exemplar{
+		TraceID:   traceIDField(line),
+		Timestamp: entry.timestamp,
+		Status:    entry.status,
+		Latency:   entry.targetProcessingTime,
+		Target:    entry.target,
+	}
+}
+
+// log writes an info log line per exemplar, ordered by minute and path.
+func (r *exemplarRecorder) log(ctx context.Context, logger *slog.Logger) {
+	keys := slices.SortedFunc(maps.Keys(r.sets), compareMetricKeys)
+	for _, key := range keys {
+		set := r.sets[key]
+		for _, e := range set.slowest {
+			logExemplar(ctx, logger, exemplarKindSlowest, key, e)
+		}
+
+		failed := slices.SortedStableFunc(slices.Values(set.failed), func(a, b exemplar) int {
+			return a.Timestamp.Compare(b.Timestamp)
+		})
+		for _, e := range failed {
+			logExemplar(ctx, logger, exemplarKindFailed, key, e)
+		}
+	}
+}
+
+func logExemplar(ctx context.Context, logger *slog.Logger, kind string, key metricKey, e exemplar) {
+	logger.LogAttrs(ctx, slog.LevelInfo, "exemplar",
+		slog.String("kind", kind),
+		slog.String("method", key.Method),
+		slog.String("host", key.Host),
+		slog.String("path", key.Path),
+		slog.Time("minute", key.Minute),
+		slog.String("trace_id", e.TraceID),
+		slog.Time("timestamp", e.Timestamp),
+		slog.Int("status", e.Status),
+		slog.Float64("latency", e.Latency),
+		slog.String("target", e.Target),
+	)
+}
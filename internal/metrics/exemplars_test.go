@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLines_Exemplars(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"example.com","pattern":"^/users$","name":"/users"}]`)
+	require.NoError(t, err)
+
+	var lines []string
+	for i, latency := range []float64{0.1, 0.5, 0.3, 0.05, -1} {
+		fields := testFields("GET", "example.com", "/users")
+		fields.TargetProcessingTime = latency
+		fields.TraceID = fmt.Sprintf("Root=1-65a5b7e0-%024d", i)
+		if latency < 0 {
+			fields.ELBStatusCode = 502
+		}
+		lines = append(lines, fields.String())
+	}
+
+	failed := testFields("GET", "example.com", "/users")
+	failed.ELBStatusCode = 500
+	failed.TargetProcessingTime = 0.2
+	failed.TraceID = "Self=1-65a5b7e0-aaaaaaaaaaaaaaaaaaaaaaaa;Root=1-65a5b7e0-bbbbbbbbbbbbbbbbbbbbbbbb"
+	lines = append(lines, failed.String())
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, Exemplars: 2})
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	var buf bytes.Buffer
+	p.exemplars.log(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	type logLine struct {
+		Kind    string  `json:"kind"`
+		Path    string  `json:"path"`
+		TraceID string  `json:"trace_id"`
+		Status  int     `json:"status"`
+		Latency float64 `json:"latency"`
+		Target  string  `json:"target"`
+	}
+	var got []logLine
+	for line := range strings.Lines(buf.String()) {
+		var l logLine
+		require.NoError(t, json.Unmarshal([]byte(line), &l))
+		got = append(got, l)
+	}
+
+	target := "192.0.2.10:8080"
+	assert.Equal(t, []logLine{
+		{Kind: exemplarKindSlowest, Path: "/users", TraceID: "1-65a5b7e0-000000000000000000000001", Status: 200, Latency: 0.5, Target: target},
+		{Kind: exemplarKindSlowest, Path: "/users", TraceID: "1-65a5b7e0-000000000000000000000002", Status: 200, Latency: 0.3, Target: target},
+		{Kind: exemplarKindFailed, Path: "/users", TraceID: "1-65a5b7e0-000000000000000000000004", Status: 502, Latency: -1, Target: target},
+		{Kind: exemplarKindFailed, Path: "/users", TraceID: "1-65a5b7e0-bbbbbbbbbbbbbbbbbbbbbbbb", Status: 500, Latency: 0.2, Target: target},
+	}, got)
+}
+
+func TestExemplarRecorder_FailedSample(t *testing.T) {
+	r := newExemplarRecorder(2)
+	picks := []int{5, 1}
+	r.intN = func(n int) int {
+		pick := picks[0]
+		picks = picks[1:]
+		return pick
+	}
+
+	for status := 500; status < 504; status++ {
+		entry := albLogEntry{method: "GET", host: "example.com", status: status, targetProcessingTime: -1}
+		r.record(entry, "/users", true, nil)
+	}
+
+	set := r.sets[metricKey{Method: "GET", Host: "example.com", Path: "/users"}]
+	require.NotNil(t, set)
+	assert.Empty(t, set.slowest)
+	assert.Equal(t, 4, set.failedSeen)
+	assert.Equal(t, []int{500, 503}, []int{set.failed[0].Status, set.failed[1].Status})
+}
+
+func TestTraceIDField(t *testing.T) {
+	fields := testFields("GET", "example.com", "/users")
+
+	tests := map[string]string{
+		"Root=1-58337281-1d84f3d73c47ec4e58577259":                                         "1-58337281-1d84f3d73c47ec4e58577259",
+		"Self=1-67891234-12456789abcdef012345678;Root=1-67891233-abcdef012345678912345678": "1-67891233-abcdef012345678912345678",
+		"-": "",
+	}
+
+	for traceID, want := range tests {
+		fields.TraceID = traceID
+		assert.Equal(t, want, traceIDField([]byte(fields.String())), traceID)
+	}
+
+	assert.Empty(t, traceIDField([]byte("https 2024-01-15T10:00:00.000000Z")))
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectGetter serves S3 objects from memory.
+type fakeObjectGetter struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (f *fakeObjectGetter) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	body, ok := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s/%s", aws.ToString(params.Bucket), aws.ToString(params.Key))
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: aws.Int64(int64(len(body))),
+	}, nil
+}
+
+// fakeMetricPutter captures PutMetricData inputs instead of sending them.
+type fakeMetricPutter struct {
+	inputs []*cloudwatch.PutMetricDataInput
+	err    error
+}
+
+func (f *fakeMetricPutter) PutMetricData(_ context.Context, params *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.inputs = append(f.inputs, params)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+// datums returns every captured datum published to the namespace.
+func (f *fakeMetricPutter) datums(namespace string) []types.MetricDatum {
+	var data []types.MetricDatum
+	for _, input := range f.inputs {
+		if aws.ToString(input.Namespace) == namespace {
+			data = append(data, input.MetricData...)
+		}
+	}
+	return data
+}
+
+// testHarness wires a Processor to in-memory S3 and CloudWatch fakes.
+type testHarness struct {
+	t         *testing.T
+	getter    *fakeObjectGetter
+	putter    *fakeMetricPutter
+	processor *Processor
+}
+
+func newTestHarness(t *testing.T, rawRules string, opts ProcessorOptions) *testHarness {
+	t.Helper()
+
+	rules, err := NewPathRules(rawRules)
+	require.NoError(t, err)
+
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	}
+
+	h := &testHarness{
+		t:      t,
+		getter: &fakeObjectGetter{objects: make(map[string][]byte)},
+		putter: &fakeMetricPutter{},
+	}
+	h.processor = NewProcwessor(h.getter, h.putter, rules, opts)
+	return h
+}
+
+// putFixture stores a file from testdata as an S3 object.
+func (h *testHarness) putFixture(bucket, key, name string) {
+	h.t.Helper()
+
+	body, err := os.ReadFile("testdata/" + name)
+	require.NoError(h.t, err)
+	h.getter.objects[bucket+"/"+key] = body
+}
+
+// putLines stores the lines as a gzip-compressed S3 object.
+func (h *testHarness) putLines(bucket, key string, lines ...string) {
+	h.t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, line := range lines {
+		_, err := gz.Write([]byte(line + "\n"))
+		require.NoError(h.t, err)
+	}
+	require.NoError(h.t, gz.Close())
+	h.getter.objects[bucket+"/"+key] = buf.Bytes()
+}
+
+// event builds an S3 notification for the objects in bucket.
+func (h *testHarness) event(bucket string, keys ...string) events.S3Event {
+	var event events.S3Event
+	for _, key := range keys {
+		var record events.S3EventRecord
+		record.S3.Bucket.Name = bucket
+		record.S3.Object.Key = key
+		event.Records = append(event.Records, record)
+	}
+	return event
+}
@@ -1,10 +1,19 @@
 package metrics
 
 import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/shiimaxx/cloudwatch-alb-path-metrics/internal/albgen"
 )
 
 func parseTime(t *testing.T, value string) time.Time {
@@ -13,3 +22,104 @@ func parseTime(t *testing.T, value string) time.Time {
 	require.NoError(t, err)
 	return parsed
 }
+
+// testLogLine builds a valid ALB log line for the given method and request URL.
+func testLogLine(method, rawURL string) string {
+	return `http 2024-01-15T10:00:00.000000Z app/my-loadbalancer/50dc6c495c0c9188 198.51.100.100:57832 203.0.113.10:80 0.000 0.001 0.000 200 200 218 587 "` +
+		method + ` ` + rawURL + ` HTTP/1.1" "Mozilla/5.0" - - arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 Root=1-65a5b7e0-4f2d8c9a7b1e3f4a5b6c7d8e api.example.com arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012 0 2024-01-15T10:00:00.000000Z forward - - - - - - -`
+}
+
+// testFields returns generator fields for a successful request; callers override what they vary.
+func testFields(method, host, path string) albgen.Fields {
+	return albgen.Fields{
+		Type:                   "https",
+		Time:                   "2024-01-15T10:00:00.000000Z",
+		ELB:                    "app/prod-alb/50dc6c495c0c9188",
+		ClientIP:               "198.51.100.100",
+		ClientPort:             57832,
+		TargetIP:               "192.0.2.10",
+		TargetPort:             "8080",
+		RequestProcessingTime:  0.001,
+		TargetProcessingTime:   0.002,
+		ResponseProcessingTime: 0.001,
+		ELBStatusCode:          200,
+		TargetStatusCode:       "200",
+		Request:                method + " https://" + host + ":443" + path + " HTTP/1.1",
+		UserAgent:              "Mozilla/5.0",
+		SSLCipher:              "ECDHE-RSA-AES128-GCM-SHA256",
+		SSLProtocol:            "TLSv1.2",
+		TargetGroupARN:         "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/my-targets/73e2d6bc24d8a067",
+		TraceID:                "Root=1-58337281-1d84f3d73c47ec4e58577259",
+		DomainName:             host,
+		ChosenCertARN:          "arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012",
+		MatchedRulePriority:    "0",
+		RequestCreationTime:    "2024-01-15T10:00:00.000000Z",
+		ActionsExecuted:        "forward",
+		RedirectURL:            "-",
+		ErrorReason:            "-",
+		PortList:               8080,
+		TargetStatusCodeList:   "200",
+		Classification:         "-",
+		ClassificationReason:   "-",
+		ConnTraceID:            "TID_1234abcd5678ef90",
+	}
+}
+
+// assertExpectedMetrics checks that the path metrics in data match the generator's ground truth exactly.
+func assertExpectedMetrics(t *testing.T, expected *albgen.ExpectedMetrics, data []types.MetricDatum) {
+	t.Helper()
+
+	type key struct {
+		method, host, path string
+		minute             time.Time
+	}
+	got := make(map[key]*albgen.ExpectedMetric)
+	latencies := make(map[key]map[float64]int)
+
+	for _, datum := range data {
+		name := aws.ToString(datum.MetricName)
+		if name != metricNameTargetResponseTime && name != metricNameRequestCount && name != metricNameFailedRequestCount {
+			continue
+		}
+
+		dims := make(map[string]string)
+		for _, d := range datum.Dimensions {
+			dims[aws.ToString(d.Name)] = aws.ToString(d.Value)
+		}
+		k := key{method: dims[metricDimensionMethod], host: dims[metricDimensionHost], path: dims[metricDimensionPath], minute: aws.ToTime(datum.Timestamp).UTC()}
+		m, ok := got[k]
+		if !ok {
+			m = &albgen.ExpectedMetric{Method: k.method, Host: k.host, Path: k.path, Minute: k.minute, TargetResponseTimes: []albgen.ValueCount{}}
+			got[k] = m
+			latencies[k] = make(map[float64]int)
+		}
+
+		switch name {
+		case metricNameRequestCount:
+			m.RequestCount += int(aws.ToFloat64(datum.Value))
+		case metricNameFailedRequestCount:
+			m.FailedRequestCount += int(aws.ToFloat64(datum.Value))
+		case metricNameTargetResponseTime:
+			for i, v := range datum.Values {
+				latencies[k][v] += int(datum.Counts[i])
+			}
+		}
+	}
+
+	actual := make([]albgen.ExpectedMetric, 0, len(got))
+	for k, m := range got {
+		for _, v := range slices.Sorted(maps.Keys(latencies[k])) {
+			m.TargetResponseTimes = append(m.TargetResponseTimes, albgen.ValueCount{Value: v, Count: latencies[k][v]})
+		}
+		actual = append(actual, *m)
+	}
+	slices.SortFunc(actual, func(a, b albgen.ExpectedMetric) int {
+		return cmp.Or(a.Minute.Compare(b.Minute), strings.Compare(a.Host, b.Host), strings.Compare(a.Path, b.Path), strings.Compare(a.Method, b.Method))
+	})
+
+	want := slices.Clone(expected.Metrics)
+	for i := range want {
+		want[i].Minute = want[i].Minute.UTC()
+	}
+	assert.Equal(t, want, actual)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+const (
+	// defaultMaxLineBytes bounds a single log line; longer lines are skipped.
+	defaultMaxLineBytes = 1 << 20
+
+	lineReaderBufferSize = 64 * 1024
+)
+
+// lineReader reads newline-terminated lines of any length up to maxBytes.
+// Unlike bufio.Scanner, a longer line is discarded and reported instead of aborting the stream.
+type lineReader struct {
+	r        *bufio.Reader
+	maxBytes int
+	buf      []byte
+}
+
+// NewLineReader returns a reader of the lines of r, skipping lines longer than maxBytes. A maxBytes of
+// zero or less selects the function's default limit.
+func NewLineReader(r io.Reader, maxBytes int) *lineReader {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxLineBytes
+	}
+
+	return &lineReader{r: bufio.NewReaderSize(r, lineReaderBufferSize), maxBytes: maxBytes}
+}
+
+// Next returns the next line without its line ending. The slice is only valid until the following call.
+// A line longer than maxBytes is discarded, and Next returns a nil line with its length as oversized.
+func (lr *lineReader) Next() (line []byte, oversized int, err error) {
+	lr.buf = lr.buf[:0]
+	var length int
+
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) && !errors.Is(err, io.EOF) {
+			return nil, 0, err
+		}
+		length += len(chunk)
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// Stop buffering once the line is known to be oversized, but keep reading to its end.
+			if length <= lr.maxBytes+len("\r\n") {
+				lr.buf = append(lr.buf, chunk...)
+			}
+			continue
+		}
+
+		if errors.Is(err, io.EOF) && length == 0 {
+			return nil, 0, io.EOF
+		}
+
+		if length > len(chunk) {
+			if length > lr.maxBytes+len("\r\n") {
+				return nil, length - (len(chunk) - len(trimLineEnding(chunk))), nil
+			}
+			lr.buf = append(lr.buf, chunk...)
+			chunk = lr.buf
+		}
+
+		line = trimLineEnding(chunk)
+		if len(line) > lr.maxBytes {
+			return nil, len(line), nil
+		}
+		return line, 0, nil
+	}
+}
+
+func trimLineEnding(b []byte) []byte {
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		b = b[:n-1]
+	}
+	if n := len(b); n > 0 && b[n-1] == '\r' {
+		b = b[:n-1]
+	}
+	return b
+}
+
+// MaxBytes returns the longest line the reader returns.
+func (lr *lineReader) MaxBytes() int {
+	return lr.maxBytes
+}
+
+// LineReader exposes the line reader for tools that read ALB access logs like the processor.
+type LineReader = lineReader
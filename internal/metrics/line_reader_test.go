@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readLine struct {
+	line      string
+	oversized int
+}
+
+func readAllLines(t *testing.T, lr *lineReader) []readLine {
+	t.Helper()
+
+	var lines []readLine
+	for {
+		line, oversized, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		require.NoError(t, err)
+		lines = append(lines, readLine{line: string(line), oversized: oversized})
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("a", 200*1024)
+	tooLong := strings.Repeat("b", 300*1024)
+
+	input := "first\r\n" + long + "\n" + tooLong + "\r\n\nlast"
+	lr := NewLineReader(strings.NewReader(input), 256*1024)
+
+	assert.Equal(t, []readLine{
+		{line: "first"},
+		{line: long},
+		{oversized: len(tooLong)},
+		{line: ""},
+		{line: "last"},
+	}, readAllLines(t, lr))
+}
+
+func TestLineReader_OversizedWithinBuffer(t *testing.T) {
+	lr := NewLineReader(strings.NewReader("short\ntoo long\nok\n"), 5)
+
+	assert.Equal(t, []readLine{
+		{line: "short"},
+		{oversized: len("too long")},
+		{line: "ok"},
+	}, readAllLines(t, lr))
+}
+
+func TestProcessLines_LongLines(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"api.example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`)
+	require.NoError(t, err)
+	processor := NewProcwessor(nil, nil, rules, ProcessorOptions{MaxLineBytes: 256 * 1024})
+
+	longUserAgent := strings.Replace(testLogLine("GET", "http://api.example.com/users/1"), `"Mozilla/5.0"`, `"`+strings.Repeat("x", 100*1024)+`"`, 1)
+	tooLongUserAgent := strings.Replace(testLogLine("GET", "http://api.example.com/users/2"), `"Mozilla/5.0"`, `"`+strings.Repeat("x", 300*1024)+`"`, 1)
+	lines := strings.Join([]string{
+		testLogLine("GET", "http://api.example.com/users/1"),
+		longUserAgent,
+		tooLongUserAgent,
+		testLogLine("GET", "http://api.example.com/users/3"),
+	}, "\n")
+
+	stats, err := processor.processLines(strings.NewReader(lines))
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.lines)
+	assert.Equal(t, map[string]int{parseErrorLineTooLong: 1}, stats.errors)
+	assert.Equal(t, 3, processor.stats.matchedLines)
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// metricSpec selects a path metric to publish, optionally under another name or in another unit.
+// In JSON it is either the metric name or an object such as {"metric":"TargetResponseTime","unit":"Milliseconds"}.
+type metricSpec struct {
+	// Metric is the built-in metric, for example TargetResponseTime.
+	Metric string `json:"metric"`
+	// Name is the published metric name. Defaults to Metric.
+	Name string `json:"name,omitempty"`
+	// Unit is the published CloudWatch unit. Values are converted from the metric's own unit.
+	Unit string `json:"unit,omitempty"`
+	// Values replaces the allowlist of a metric broken down by an ALB field; other values are counted as Other.
+	Values []string `json:"values,omitempty"`
+}
+
+// metricDefinition describes how a built-in metric is computed from an aggregate.
+type metricDefinition struct {
+	unit types.StandardUnit
+	// count returns the value of a counter metric.
+	count func(agg *metricAggregate) float64
+	// samples returns the observations of a distribution metric, published as Values and Counts.
+	samples func(agg *metricAggregate) []float64
+	// labels returns the counts per value of an ALB field, published with one datum per value in dimension.
+	labels    func(agg *metricAggregate) map[string]int
+	dimension string
+	// allowlist is the default set of values published in dimension.
+	allowlist []string
+}
+
+var metricDefinitions = map[string]metricDefinition{
+	metricNameTargetResponseTime: {
+		unit:    types.StandardUnitSeconds,
+		samples: func(agg *metricAggregate) []float64 { return agg.targetResponseTime },
+	},
+	metricNameReceivedBytes: {
+		unit:    types.StandardUnitBytes,
+		samples: func(agg *metricAggregate) []float64 { return agg.receivedBytes },
+	},
+	metricNameSentBytes: {
+		unit:    types.StandardUnitBytes,
+		samples: func(agg *metricAggregate) []float64 { return agg.sentBytes },
+	},
+	metricNameThroughput: {
+		unit:    types.StandardUnitBytesSecond,
+		samples: func(agg *metricAggregate) []float64 { return agg.throughput },
+	},
+	metricNameRequestCount: {
+		unit:  types.StandardUnitCount,
+		count: func(agg *metricAggregate) float64 { return float64(agg.requestCount) },
+	},
+	metricNameFailedRequestCount: {
+		unit:  types.StandardUnitCount,
+		count: func(agg *metricAggregate) float64 { return float64(agg.failedRequestCount) },
+	},
+	metricNameTargetUnavailable: {
+		unit:  types.StandardUnitCount,
+		count: func(agg *metricAggregate) float64 { return float64(agg.targetUnavailableCount) },
+	},
+	metricNameErrorReasonCount: {
+		unit:      types.StandardUnitCount,
+		labels:    func(agg *metricAggregate) map[string]int { return agg.errorReasons },
+		dimension: metricDimensionReason,
+		allowlist: errorReasons,
+	},
+	metricNameActionsExecutedCount: {
+		unit:      types.StandardUnitCount,
+		labels:    func(agg *metricAggregate) map[string]int { return agg.actionsExecuted },
+		dimension: metricDimensionAction,
+		allowlist: actions,
+	},
+	metricNameClassificationCount: {
+		unit:      types.StandardUnitCount,
+		labels:    func(agg *metricAggregate) map[string]int { return agg.classifications },
+		dimension: metricDimensionClassification,
+		allowlist: classifications,
+	},
+	metricNameClassificationReasonCount: {
+		unit:      types.StandardUnitCount,
+		labels:    func(agg *metricAggregate) map[string]int { return agg.classificationReasons },
+		dimension: metricDimensionReason,
+		allowlist: classificationReasons,
+	},
+}
+
+// defaultMetricSpecs are published for rules that do not select their own metrics.
+var defaultMetricSpecs = []metricSpec{
+	{Metric: metricNameTargetResponseTime},
+	{Metric: metricNameRequestCount},
+	{Metric: metricNameFailedRequestCount},
+}
+
+// unitScales maps a metric's own unit to the units it can be published in and the factor to convert by.
+var unitScales = map[types.StandardUnit]map[types.StandardUnit]float64{
+	types.StandardUnitSeconds: {
+		types.StandardUnitSeconds:      1,
+		types.StandardUnitMilliseconds: 1e3,
+		types.StandardUnitMicroseconds: 1e6,
+	},
+	types.StandardUnitBytes: {
+		types.StandardUnitBytes:     1,
+		types.StandardUnitKilobytes: 1e-3,
+		types.StandardUnitMegabytes: 1e-6,
+		types.StandardUnitBits:      8,
+	},
+	types.StandardUnitBytesSecond: {
+		types.StandardUnitBytesSecond:     1,
+		types.StandardUnitKilobytesSecond: 1e-3,
+		types.StandardUnitMegabytesSecond: 1e-6,
+		types.StandardUnitBitsSecond:      8,
+	},
+	types.StandardUnitCount: {
+		types.StandardUnitCount: 1,
+	},
+}
+
+func (s *metricSpec) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '"' {
+		*s = metricSpec{}
+		return json.Unmarshal(trimmed, &s.Metric)
+	}
+
+	// The alias drops this method so that the object form decodes normally.
+	type plain metricSpec
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// name returns the published metric name.
+func (s metricSpec) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Metric
+}
+
+// resolve returns the metric definition with the published unit and the factor its values are converted by.
+func (s metricSpec) resolve() (metricDefinition, types.StandardUnit, float64, error) {
+	def, ok := metricDefinitions[s.Metric]
+	if !ok {
+		return metricDefinition{}, "", 0, fmt.Errorf("unknown metric %q (known metrics: %s)", s.Metric, strings.Join(knownMetricNames(), ", "))
+	}
+
+	if s.Unit == "" {
+		return def, def.unit, 1, nil
+	}
+
+	unit := types.StandardUnit(s.Unit)
+	scale, ok := unitScales[def.unit][unit]
+	if !ok {
+		return metricDefinition{}, "", 0, fmt.Errorf("metric %s cannot be published in %s", s.Metric, s.Unit)
+	}
+	return def, unit, scale, nil
+}
+
+// validateMetricSpecs checks that every spec names a known metric and a compatible unit, and that
+// published names are unique.
+func validateMetricSpecs(specs []metricSpec) error {
+	names := make(map[string]bool, len(specs))
+	for idx, spec := range specs {
+		if _, _, _, err := spec.resolve(); err != nil {
+			return fmt.Errorf("metric %d: %w", idx, err)
+		}
+
+		if len(spec.Values) > 0 {
+			if def := metricDefinitions[spec.Metric]; def.labels == nil {
+				return fmt.Errorf("metric %d: %s does not take values", idx, spec.Metric)
+			}
+			if len(spec.Values) > maxMetricSpecValues {
+				return fmt.Errorf("metric %d: at most %d values are allowed", idx, maxMetricSpecValues)
+			}
+		}
+
+		if names[spec.name()] {
+			return fmt.Errorf("metric %d: name %q is published more than once", idx, spec.name())
+		}
+		names[spec.name()] = true
+	}
+	return nil
+}
+
+// ParseMetricSpecs parses a JSON metric selection such as ["RequestCount", {"metric":"TargetResponseTime","unit":"Milliseconds"}].
+// An empty string returns nil, which selects the default metrics.
+func ParseMetricSpecs(raw string) ([]MetricSpec, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var specs []metricSpec
+	if err := json.Unmarshal([]byte(trimmed), &specs); err != nil {
+		return nil, fmt.Errorf("failed to parse metrics JSON: %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("metrics must not be empty")
+	}
+	if err := validateMetricSpecs(specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func knownMetricNames() []string {
+	return slices.Sorted(maps.Keys(metricDefinitions))
+}
+
+// scaleValue converts v by scale, rounding away binary noise such as 0.123*1000 = 123.00000000000001.
+func scaleValue(v, scale float64) float64 {
+	if scale == 1 {
+		return v
+	}
+
+	return roundSignificant(v * scale)
+}
+
+// roundSignificant rounds v to 12 significant digits.
+func roundSignificant(v float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
+
+// MetricSpec exposes the metric selection type for configuration.
+type MetricSpec = metricSpec
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetricSpecs(t *testing.T) {
+	specs, err := ParseMetricSpecs(`["RequestCount", {"metric":"TargetResponseTime","name":"TargetResponseTimeMs","unit":"Milliseconds"}]`)
+
+	require.NoError(t, err)
+	assert.Equal(t, []MetricSpec{
+		{Metric: metricNameRequestCount},
+		{Metric: metricNameTargetResponseTime, Name: "TargetResponseTimeMs", Unit: "Milliseconds"},
+	}, specs)
+
+	specs, err = ParseMetricSpecs("  ")
+	require.NoError(t, err)
+	assert.Nil(t, specs)
+}
+
+func TestParseMetricSpecs_Errors(t *testing.T) {
+	tests := map[string]struct {
+		raw  string
+		want string
+	}{
+		"invalid JSON":      {raw: `[`, want: "failed to parse metrics JSON"},
+		"empty":             {raw: `[]`, want: "must not be empty"},
+		"unknown metric":    {raw: `["Latency"]`, want: `unknown metric "Latency"`},
+		"incompatible unit": {raw: `[{"metric":"RequestCount","unit":"Milliseconds"}]`, want: "cannot be published in Milliseconds"},
+		"duplicate name": {
+			raw:  `["TargetResponseTime", {"metric":"TargetResponseTime","unit":"Milliseconds"}]`,
+			want: `name "TargetResponseTime" is published more than once`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseMetricSpecs(tt.raw)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestNewPathRules_Metrics(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/health$","name":"/health","metrics":["RequestCount"]},
+		{"host":"example.com","pattern":"^/users$","name":"/users"}
+	]`)
+	require.NoError(t, err)
+	assert.Equal(t, []metricSpec{{Metric: metricNameRequestCount}}, rules.rules[0].metrics)
+	assert.Nil(t, rules.rules[1].metrics)
+
+	_, err = NewPathRules(`[{"host":"example.com","pattern":"^/$","name":"/","metrics":[]}]`)
+	assert.ErrorContains(t, err, "path rule 0: metrics must not be empty")
+
+	_, err = NewPathRules(`[{"host":"example.com","pattern":"^/$","name":"/","metrics":["Nope"]}]`)
+	assert.ErrorContains(t, err, `path rule 0: metric 0: unknown metric "Nope"`)
+}
+
+func TestProcessLines_MetricSelection(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/health$","name":"/health","metrics":["RequestCount"]},
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}
+	]`)
+	require.NoError(t, err)
+
+	defaults, err := ParseMetricSpecs(`[{"metric":"TargetResponseTime","name":"Latency","unit":"Milliseconds"}, "FailedRequestCount"]`)
+	require.NoError(t, err)
+
+	health := testFields("GET", "example.com", "/health")
+	users := testFields("GET", "example.com", "/users/1")
+	users.TargetProcessingTime = 0.123
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, Metrics: defaults})
+	require.NoError(t, p.ProcessLines(strings.NewReader(health.String()+"\n"+users.String()+"\n")))
+
+	published := make(map[string]types.MetricDatum)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		published[aws.ToString(datum.Dimensions[2].Value)+" "+aws.ToString(datum.MetricName)] = datum
+	}
+	require.Len(t, published, 3)
+
+	assert.Equal(t, 1.0, aws.ToFloat64(published["/health RequestCount"].Value))
+
+	latency := published["/users/:id Latency"]
+	assert.Equal(t, types.StandardUnitMilliseconds, latency.Unit)
+	assert.Equal(t, []float64{123}, latency.Values)
+	assert.Equal(t, []float64{1}, latency.Counts)
+
+	assert.Equal(t, 0.0, aws.ToFloat64(published["/users/:id FailedRequestCount"].Value))
+}
+
+func TestScaleValue(t *testing.T) {
+	assert.Equal(t, 123.0, scaleValue(0.123, 1e3))
+	assert.Equal(t, 1500.0, scaleValue(0.0015, 1e6))
+	assert.Equal(t, 0.1, scaleValue(0.1, 1))
+}
@@ -1,21 +1,78 @@
 package metrics
 
 import (
-	"encoding/csv"
 	"errors"
+	"net/netip"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Parse error reasons used to classify malformed log lines.
+const (
+	parseErrorMissingFields        = "MissingFields"
+	parseErrorTimestamp            = "Timestamp"
+	parseErrorStatus               = "Status"
+	parseErrorTargetProcessingTime = "TargetProcessingTime"
+	parseErrorProcessingTime       = "ProcessingTime"
+	parseErrorBytes                = "Bytes"
+	parseErrorRequest              = "Request"
+	parseErrorQuote                = "Quote"
+	parseErrorMalformed            = "Malformed"
+	parseErrorTruncatedObject      = "TruncatedObject"
+	parseErrorLineTooLong          = "LineTooLong"
+)
+
+// parseError is returned by the parser and carries the reason the line was rejected.
+type parseError struct {
+	reason string
+	msg    string
+}
+
+func (e *parseError) Error() string {
+	return e.msg
+}
+
+func newParseError(reason, msg string) error {
+	return &parseError{reason: reason, msg: msg}
+}
+
+// parseErrorReason returns the classification for an error returned by the parser.
+func parseErrorReason(err error) string {
+	var pe *parseError
+	if errors.As(err, &pe) {
+		return pe.reason
+	}
+	return parseErrorMalformed
+}
+
 type albLogEntry struct {
-	timestamp            time.Time
-	method               string
-	host                 string
-	path                 string
-	status               int
+	timestamp time.Time
+	method    string
+	host      string
+	path      string
+	status    int
+	// clientAddr is the IP address of the client:port field, the zero Addr when it is missing or malformed.
+	clientAddr           netip.Addr
 	targetProcessingTime float64
+	// requestProcessingTime and responseProcessingTime are -1, like targetProcessingTime, when no target responded.
+	requestProcessingTime  float64
+	responseProcessingTime float64
+	// target is the target:port field, "-" when the request was not sent to a target.
+	target string
+	// receivedBytes and sentBytes are -1 when ALB logged "-".
+	receivedBytes int64
+	sentBytes     int64
+	userAgent     string
+	// sslCipher and sslProtocol are "-" for plain HTTP requests.
+	sslCipher   string
+	sslProtocol string
+	// The fields below are empty when the log line predates them.
+	actionsExecuted      string
+	errorReason          string
+	classification       string
+	classificationReason string
 }
 
 // AWS ALB log field constants (0-based indices)
@@ -26,36 +83,73 @@ type albLogEntry struct {
 // "actions_executed" "redirect_url" "error_reason" "target:port_list" "target_status_code_list"
 // "classification" "classification_reason" conn_trace_id "transformed_host" "transformed_uri" "request_transform_status"
 const (
-	timestampFieldIndex            = 1
-	targetProcessingTimeFieldIndex = 6
-	statusFieldIndex               = 8
-	requestFieldIndex              = 12
+	timestampFieldIndex              = 1
+	clientFieldIndex                 = 3
+	targetFieldIndex                 = 4
+	requestProcessingTimeFieldIndex  = 5
+	targetProcessingTimeFieldIndex   = 6
+	responseProcessingTimeFieldIndex = 7
+	statusFieldIndex                 = 8
+	receivedBytesFieldIndex          = 10
+	sentBytesFieldIndex              = 11
+	requestFieldIndex                = 12
+	userAgentFieldIndex              = 13
+	sslCipherFieldIndex              = 14
+	sslProtocolFieldIndex            = 15
+	traceIDFieldIndex                = 17
+	actionsExecutedFieldIndex        = 22
+	errorReasonFieldIndex            = 24
+	classificationFieldIndex         = 27
+	classificationReasonFieldIndex   = 28
+
+	// lastFieldIndex is the last field the parser reads.
+	lastFieldIndex = classificationReasonFieldIndex
 )
 
 func parseALBLogFields(fields []string) (*albLogEntry, error) {
 	if len(fields) <= requestFieldIndex {
-		return nil, errors.New("invalid ALB log entry: missing required fields")
+		return nil, newParseError(parseErrorMissingFields, "invalid ALB log entry: missing required fields")
 	}
 
 	// Parse timestamp (ISO 8601 format: 2018-07-02T22:23:00.186641Z)
 	timestamp, err := time.Parse(time.RFC3339Nano, fields[timestampFieldIndex])
 	if err != nil {
-		return nil, errors.New("failed to parse timestamp: " + err.Error())
+		return nil, newParseError(parseErrorTimestamp, "failed to parse timestamp: "+err.Error())
 	}
 
 	status, err := strconv.Atoi(fields[statusFieldIndex])
 	if err != nil {
-		return nil, errors.New("failed to parse status: " + err.Error())
+		return nil, newParseError(parseErrorStatus, "failed to parse status: "+err.Error())
 	}
 
 	targetProcessingTime, err := strconv.ParseFloat(fields[targetProcessingTimeFieldIndex], 64)
 	if err != nil {
-		return nil, errors.New("failed to parse target processing time: " + err.Error())
+		return nil, newParseError(parseErrorTargetProcessingTime, "failed to parse target processing time: "+err.Error())
+	}
+
+	requestProcessingTime, err := strconv.ParseFloat(fields[requestProcessingTimeFieldIndex], 64)
+	if err != nil {
+		return nil, newParseError(parseErrorProcessingTime, "failed to parse request processing time: "+err.Error())
+	}
+
+	responseProcessingTime, err := strconv.ParseFloat(fields[responseProcessingTimeFieldIndex], 64)
+	if err != nil {
+		return nil, newParseError(parseErrorProcessingTime, "failed to parse response processing time: "+err.Error())
+	}
+
+	receivedBytes, err := parseByteCount(fields[receivedBytesFieldIndex])
+	if err != nil {
+		return nil, newParseError(parseErrorBytes, "failed to parse received bytes: "+err.Error())
+	}
+
+	sentBytes, err := parseByteCount(fields[sentBytesFieldIndex])
+	if err != nil {
+		return nil, newParseError(parseErrorBytes, "failed to parse sent bytes: "+err.Error())
 	}
 
 	requestParts := strings.Fields(fields[requestFieldIndex])
 	if len(requestParts) < 3 {
-		return nil, errors.New("invalid request field format")
+		return nil, newParseError(parseErrorRequest, "invalid request field format")
 	}
 
 	method := requestParts[0]
@@ -63,28 +157,137 @@ func parseALBLogFields(fields []string) (*albLogEntry, error) {
 
 	u, err := url.Parse(urlStr)
 	if err != nil {
-		return nil, errors.New("failed to parse request URL: " + err.Error())
+		return nil, newParseError(parseErrorRequest, "failed to parse request URL: "+err.Error())
 	}
 
 	return &albLogEntry{
-		timestamp:            timestamp,
-		method:               method,
-		host:                 u.Hostname(),
-		path:                 u.Path,
-		status:               status,
-		targetProcessingTime: targetProcessingTime,
+		timestamp:              timestamp,
+		method:                 method,
+		host:                   u.Hostname(),
+		path:                   u.Path,
+		status:                 status,
+		clientAddr:             parseClientAddr(fields[clientFieldIndex]),
+		targetProcessingTime:   targetProcessingTime,
+		requestProcessingTime:  requestProcessingTime,
+		responseProcessingTime: responseProcessingTime,
+		target:                 fields[targetFieldIndex],
+		receivedBytes:          receivedBytes,
+		sentBytes:              sentBytes,
+		userAgent:              optionalField(fields, userAgentFieldIndex),
+		sslCipher:              optionalField(fields, sslCipherFieldIndex),
+		sslProtocol:            optionalField(fields, sslProtocolFieldIndex),
+		actionsExecuted:        optionalField(fields, actionsExecutedFieldIndex),
+		errorReason:            optionalField(fields, errorReasonFieldIndex),
+		classification:         optionalField(fields, classificationFieldIndex),
+		classificationReason:   optionalField(fields, classificationReasonFieldIndex),
 	}, nil
 }
 
-func parseALBLogLine(line string) (*albLogEntry, error) {
-	reader := csv.NewReader(strings.NewReader(line))
-	reader.Comma = ' '
-	reader.ReuseRecord = true
+// optionalField returns the field at idx, or an empty string for log lines written before ALB added it.
+func optionalField(fields []string, idx int) string {
+	if idx < len(fields) {
+		return fields[idx]
+	}
+	return ""
+}
 
-	fields, err := reader.Read()
+// parseClientAddr returns the IP address of a client:port field such as 198.51.100.1:57832 or
+// 2001:db8::1:57832, or the zero Addr when the field holds none.
+func parseClientAddr(s string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().WithZone("").Unmap()
+	}
+
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		s = s[:i]
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.WithZone("").Unmap()
+}
+
+// parseByteCount parses a received_bytes or sent_bytes field, returning -1 for "-".
+func parseByteCount(s string) (int64, error) {
+	if s == "-" {
+		return -1, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseALBLogLine(line string) (*albLogEntry, error) {
+	fields, err := splitALBLogFields(line, nil)
 	if err != nil {
-		return nil, errors.New("failed to parse ALB log line: " + err.Error())
+		return nil, err
 	}
 
 	return parseALBLogFields(fields)
 }
+
+// splitALBLogFields splits a log line on single spaces, honoring double-quoted fields in which "" stands for a quote.
+// The fields are appended to dst[:0] and share memory with line.
+func splitALBLogFields(line string, dst []string) ([]string, error) {
+	fields := dst[:0]
+
+	for {
+		var field string
+		var err error
+		field, line, err = nextALBLogField(line)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+
+		if line == "" {
+			return fields, nil
+		}
+		// Skip the separator; a trailing separator yields a final empty field.
+		line = line[1:]
+	}
+}
+
+// nextALBLogField returns the first field of line and the remainder starting at its separator.
+func nextALBLogField(line string) (field, rest string, err error) {
+	if !strings.HasPrefix(line, `"`) {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+
+		field = line[:end]
+		if strings.IndexByte(field, '"') >= 0 {
+			return "", "", newParseError(parseErrorQuote, `failed to parse ALB log line: bare " in non-quoted field`)
+		}
+		return field, line[end:], nil
+	}
+
+	escaped := false
+	i := 1
+	for {
+		j := strings.IndexByte(line[i:], '"')
+		if j < 0 {
+			return "", "", newParseError(parseErrorQuote, `failed to parse ALB log line: missing closing " in quoted field`)
+		}
+		i += j
+
+		if i+1 < len(line) && line[i+1] == '"' {
+			escaped = true
+			i += 2
+			continue
+		}
+		break
+	}
+
+	field = line[1:i]
+	rest = line[i+1:]
+	if rest != "" && rest[0] != ' ' {
+		return "", "", newParseError(parseErrorQuote, `failed to parse ALB log line: extraneous " in field`)
+	}
+
+	if escaped {
+		field = strings.ReplaceAll(field, `""`, `"`)
+	}
+
+	return field, rest, nil
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	metricNameParseErrorCount = "ParseErrorCount"
+
+	metricDimensionReason = "Reason"
+
+	defaultParseErrorSamples = 5
+)
+
+// parseErrorSample keeps a rejected line for logging.
+type parseErrorSample struct {
+	lineNumber int
+	reason     string
+	err        string
+	line       string
+}
+
+// parseErrorStats counts lines read and parse failures by reason.
+type parseErrorStats struct {
+	lines      int
+	errors     map[string]int
+	samples    []parseErrorSample
+	maxSamples int
+}
+
+func newParseErrorStats(maxSamples int) *parseErrorStats {
+	return &parseErrorStats{errors: make(map[string]int), maxSamples: maxSamples}
+}
+
+// record counts a parse failure and keeps the line as a sample while below maxSamples.
+func (s *parseErrorStats) record(lineNumber int, line string, err error) {
+	reason := parseErrorReason(err)
+	s.errors[reason]++
+
+	if len(s.samples) < s.maxSamples {
+		s.samples = append(s.samples, parseErrorSample{lineNumber: lineNumber, reason: reason, err: err.Error(), line: line})
+	}
+}
+
+// total returns the number of parse failures across all reasons.
+func (s *parseErrorStats) total() int {
+	var total int
+	for _, count := range s.errors {
+		total += count
+	}
+	return total
+}
+
+// rate returns the fraction of lines that failed to parse.
+func (s *parseErrorStats) rate() float64 {
+	if s.lines == 0 {
+		if s.total() > 0 {
+			return 1
+		}
+		return 0
+	}
+	return float64(s.total()) / float64(s.lines)
+}
+
+// merge adds the counts from other, keeping samples up to maxSamples.
+func (s *parseErrorStats) merge(other *parseErrorStats) {
+	s.lines += other.lines
+	for reason, count := range other.errors {
+		s.errors[reason] += count
+	}
+	for _, sample := range other.samples {
+		if len(s.samples) >= s.maxSamples {
+			break
+		}
+		s.samples = append(s.samples, sample)
+	}
+}
+
+// metricData materializes the parse failures as ParseErrorCount data points.
+func (s *parseErrorStats) metricData(timestamp time.Time) []types.MetricDatum {
+	var metricData []types.MetricDatum
+	for _, reason := range slices.Sorted(maps.Keys(s.errors)) {
+		metricData = append(metricData, types.MetricDatum{
+			MetricName: aws.String(metricNameParseErrorCount),
+			Timestamp:  aws.Time(timestamp),
+			Dimensions: []types.Dimension{
+				{Name: aws.String(metricDimensionReason), Value: aws.String(reason)},
+			},
+			Value: aws.Float64(float64(s.errors[reason])),
+			Unit:  types.StandardUnitCount,
+		})
+	}
+	return metricData
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorStats_MergeAndMetricData(t *testing.T) {
+	first := newParseErrorStats(2)
+	first.lines = 10
+	first.record(1, "a", newParseError(parseErrorTimestamp, "bad timestamp"))
+	first.record(2, "b", newParseError(parseErrorQuote, "bad quote"))
+
+	second := newParseErrorStats(2)
+	second.lines = 5
+	second.record(3, "c", errors.New("unknown"))
+	second.record(4, "d", newParseError(parseErrorTimestamp, "bad timestamp"))
+
+	total := newParseErrorStats(3)
+	total.merge(first)
+	total.merge(second)
+
+	assert.Equal(t, 15, total.lines)
+	assert.Equal(t, 4, total.total())
+	assert.Len(t, total.samples, 3)
+
+	timestamp := parseTime(t, "2024-01-15T10:00:00Z")
+	metricData := total.metricData(timestamp)
+	require.Len(t, metricData, 3)
+	assert.Equal(t, metricNameParseErrorCount, *metricData[2].MetricName)
+	assert.Equal(t, parseErrorTimestamp, *metricData[2].Dimensions[0].Value)
+	assert.Equal(t, float64(2), *metricData[2].Value)
+	assert.Equal(t, timestamp, *metricData[2].Timestamp)
+}
@@ -0,0 +1,433 @@
+package metrics
+
+import (
+	"bytes"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxInternedStrings bounds the number of distinct string values kept by albLogParser.
+	maxInternedStrings = 10000
+	// maxInternedLength is the longest value albLogParser interns; longer values are allocated per line.
+	maxInternedLength = 256
+)
+
+var (
+	// float64Pow10 holds the powers of ten that are exactly representable as float64.
+	float64Pow10 = [...]float64{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11, 1e12, 1e13, 1e14, 1e15}
+	// nanosPerDigits maps the number of fraction digits to the nanoseconds one unit of them represents.
+	nanosPerDigits = [...]int{1e9, 1e8, 1e7, 1e6, 1e5, 1e4, 1e3, 1e2, 1e1, 1e0}
+)
+
+// albLogParser parses ALB log lines held in byte slices.
+//
+// It tokenizes only up to the last field that metrics need, parses numbers and timestamps in place,
+// and splits the request without url.Parse. Method, host, path and the other string fields are
+// interned, so repeated values do not allocate. Lines the fast path does not understand fall back to parseALBLogFields, so
+// results match parseALBLogLine; the only difference is that fields after the last one needed are
+// never read, and malformed quoting there is not rejected.
+type albLogParser struct {
+	interned map[string]string
+	fields   []string
+}
+
+func newALBLogParser() *albLogParser {
+	return &albLogParser{interned: make(map[string]string)}
+}
+
+// parse extracts an entry from line. The entry does not reference line after parse returns.
+func (p *albLogParser) parse(line []byte) (albLogEntry, error) {
+	var spans [lastFieldIndex + 1][]byte
+	if n, ok := tokenizeALBLogFields(line, spans[:]); !ok || n <= requestFieldIndex {
+		return p.parseSlow(line)
+	}
+
+	timestamp, ok := parseALBTimestamp(spans[timestampFieldIndex])
+	if !ok {
+		return p.parseSlow(line)
+	}
+
+	status, err := parseStatusBytes(spans[statusFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorStatus, "failed to parse status: "+err.Error())
+	}
+
+	targetProcessingTime, err := parseFloatBytes(spans[targetProcessingTimeFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorTargetProcessingTime, "failed to parse target processing time: "+err.Error())
+	}
+
+	requestProcessingTime, err := parseFloatBytes(spans[requestProcessingTimeFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorProcessingTime, "failed to parse request processing time: "+err.Error())
+	}
+
+	responseProcessingTime, err := parseFloatBytes(spans[responseProcessingTimeFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorProcessingTime, "failed to parse response processing time: "+err.Error())
+	}
+
+	receivedBytes, err := parseByteCountBytes(spans[receivedBytesFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorBytes, "failed to parse received bytes: "+err.Error())
+	}
+
+	sentBytes, err := parseByteCountBytes(spans[sentBytesFieldIndex])
+	if err != nil {
+		return albLogEntry{}, newParseError(parseErrorBytes, "failed to parse sent bytes: "+err.Error())
+	}
+
+	method, host, path, ok := splitRequestBytes(spans[requestFieldIndex])
+	if !ok {
+		return p.parseSlow(line)
+	}
+
+	return albLogEntry{
+		timestamp:              timestamp,
+		method:                 p.intern(method),
+		host:                   p.intern(host),
+		path:                   p.intern(path),
+		status:                 status,
+		clientAddr:             parseClientAddrBytes(spans[clientFieldIndex]),
+		targetProcessingTime:   targetProcessingTime,
+		requestProcessingTime:  requestProcessingTime,
+		responseProcessingTime: responseProcessingTime,
+		target:                 p.intern(spans[targetFieldIndex]),
+		receivedBytes:          receivedBytes,
+		sentBytes:              sentBytes,
+		userAgent:              p.intern(spans[userAgentFieldIndex]),
+		sslCipher:              p.intern(spans[sslCipherFieldIndex]),
+		sslProtocol:            p.intern(spans[sslProtocolFieldIndex]),
+		actionsExecuted:        p.intern(spans[actionsExecutedFieldIndex]),
+		errorReason:            p.intern(spans[errorReasonFieldIndex]),
+		classification:         p.intern(spans[classificationFieldIndex]),
+		classificationReason:   p.intern(spans[classificationReasonFieldIndex]),
+	}, nil
+}
+
+// parseSlow parses the whole line with the reference field splitter.
+func (p *albLogParser) parseSlow(line []byte) (albLogEntry, error) {
+	fields, err := splitALBLogFields(string(line), p.fields)
+	if err != nil {
+		return albLogEntry{}, err
+	}
+	p.fields = fields
+
+	entry, err := parseALBLogFields(fields)
+	if err != nil {
+		return albLogEntry{}, err
+	}
+	return *entry, nil
+}
+
+// intern returns a string equal to b, reusing a previous allocation when possible.
+func (p *albLogParser) intern(b []byte) string {
+	if s, ok := p.interned[string(b)]; ok {
+		return s
+	}
+
+	s := string(b)
+	if len(b) <= maxInternedLength && len(p.interned) < maxInternedStrings {
+		p.interned[s] = s
+	}
+	return s
+}
+
+// tokenizeALBLogFields fills spans with the leading fields of line, stripping quotes, and returns how
+// many it filled; the rest are left nil when the line is shorter. It returns false when the line uses
+// quoting the fast path does not handle.
+func tokenizeALBLogFields(line []byte, spans [][]byte) (int, bool) {
+	for i := range spans {
+		if i > 0 {
+			if len(line) == 0 {
+				return i, true
+			}
+			if line[0] != ' ' {
+				return 0, false
+			}
+			line = line[1:]
+		}
+
+		if len(line) > 0 && line[0] == '"' {
+			end := bytes.IndexByte(line[1:], '"')
+			if end < 0 {
+				return 0, false
+			}
+			end++
+
+			// Escaped quotes and text after the closing quote are left to the slow path.
+			if end+1 < len(line) && line[end+1] != ' ' {
+				return 0, false
+			}
+			spans[i] = line[1:end]
+			line = line[end+1:]
+			continue
+		}
+
+		end := bytes.IndexByte(line, ' ')
+		if end < 0 {
+			end = len(line)
+		}
+		if bytes.IndexByte(line[:end], '"') >= 0 {
+			return 0, false
+		}
+		spans[i] = line[:end]
+		line = line[end:]
+	}
+
+	return len(spans), true
+}
+
+// traceIDField returns the X-Ray trace ID in the trace_id field of line, such as 1-58337262-36d228ad5d99923122bbe354,
+// or an empty string when the line has none. It is read separately from parse so that only the few
+// lines kept as exemplars pay for allocating it.
+func traceIDField(line []byte) string {
+	var spans [traceIDFieldIndex + 1][]byte
+	var field string
+	if n, ok := tokenizeALBLogFields(line, spans[:]); ok {
+		if n <= traceIDFieldIndex {
+			return ""
+		}
+		field = string(spans[traceIDFieldIndex])
+	} else {
+		fields, err := splitALBLogFields(string(line), nil)
+		if err != nil || len(fields) <= traceIDFieldIndex {
+			return ""
+		}
+		field = fields[traceIDFieldIndex]
+	}
+
+	for part := range strings.SplitSeq(field, ";") {
+		if root, ok := strings.CutPrefix(part, "Root="); ok {
+			return root
+		}
+	}
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
+// parseALBTimestamp parses the UTC layout ALB writes, 2006-01-02T15:04:05.000000Z.
+func parseALBTimestamp(b []byte) (time.Time, bool) {
+	if len(b) < len("2006-01-02T15:04:05Z") || b[4] != '-' || b[7] != '-' || b[10] != 'T' ||
+		b[13] != ':' || b[16] != ':' || b[len(b)-1] != 'Z' {
+		return time.Time{}, false
+	}
+
+	year, ok1 := atoiDigits(b[0:4])
+	month, ok2 := atoiDigits(b[5:7])
+	day, ok3 := atoiDigits(b[8:10])
+	hour, ok4 := atoiDigits(b[11:13])
+	minute, ok5 := atoiDigits(b[14:16])
+	second, ok6 := atoiDigits(b[17:19])
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 {
+		return time.Time{}, false
+	}
+
+	var nanos int
+	if frac := b[19 : len(b)-1]; len(frac) > 0 {
+		if frac[0] != '.' || len(frac) < 2 || len(frac) > 10 {
+			return time.Time{}, false
+		}
+
+		digits, ok := atoiDigits(frac[1:])
+		if !ok {
+			return time.Time{}, false
+		}
+		nanos = digits * nanosPerDigits[len(frac)-1]
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > daysIn(time.Month(month), year) ||
+		hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, false
+	}
+
+	return time.Date(year, time.Month(month), day, hour, minute, second, nanos, time.UTC), true
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// atoiDigits parses a short run of ASCII digits.
+func atoiDigits(b []byte) (int, bool) {
+	if len(b) == 0 || len(b) > 9 {
+		return 0, false
+	}
+
+	var n int
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// parseStatusBytes parses a status code, deferring to strconv.Atoi for anything but plain digits.
+func parseStatusBytes(b []byte) (int, error) {
+	if n, ok := atoiDigits(b); ok {
+		return n, nil
+	}
+	return strconv.Atoi(string(b))
+}
+
+// parseByteCountBytes parses a byte count such as 587 in place, deferring to parseByteCount otherwise.
+func parseByteCountBytes(b []byte) (int64, error) {
+	if n, ok := atoiDigits(b); ok {
+		return int64(n), nil
+	}
+	return parseByteCount(string(b))
+}
+
+// parseClientAddrBytes parses an IPv4 client:port field in place, deferring to parseClientAddr otherwise.
+func parseClientAddrBytes(b []byte) netip.Addr {
+	var ip [4]byte
+	rest := b
+	for i := range ip {
+		sep := byte('.')
+		if i == len(ip)-1 {
+			sep = ':'
+		}
+
+		end := bytes.IndexByte(rest, sep)
+		if end < 0 {
+			return parseClientAddr(string(b))
+		}
+
+		n, ok := atoiDigits(rest[:end])
+		if !ok || n > 255 || end > 1 && rest[0] == '0' {
+			return parseClientAddr(string(b))
+		}
+		ip[i] = byte(n)
+		rest = rest[end+1:]
+	}
+
+	if port, ok := atoiDigits(rest); !ok || port > 65535 {
+		return parseClientAddr(string(b))
+	}
+	return netip.AddrFrom4(ip)
+}
+
+// parseFloatBytes parses decimals such as 0.003 and -1 in place, deferring to strconv.ParseFloat otherwise.
+func parseFloatBytes(b []byte) (float64, error) {
+	s := b
+	negative := len(s) > 0 && s[0] == '-'
+	if negative {
+		s = s[1:]
+	}
+
+	var mantissa int64
+	var digits, fracDigits int
+	seenDot := false
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			mantissa = mantissa*10 + int64(c-'0')
+			digits++
+			if seenDot {
+				fracDigits++
+			}
+		case c == '.' && !seenDot:
+			seenDot = true
+		default:
+			return strconv.ParseFloat(string(b), 64)
+		}
+	}
+
+	if digits == 0 || digits > 15 {
+		return strconv.ParseFloat(string(b), 64)
+	}
+
+	// Both operands are exact, so the division is correctly rounded like strconv.ParseFloat.
+	v := float64(mantissa) / float64Pow10[fracDigits]
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// splitRequestBytes extracts the method, host and path from a request field such as
+// "GET https://example.com:443/users/1?x=1 HTTP/1.1". It returns false for requests that need url.Parse.
+func splitRequestBytes(request []byte) (method, host, path []byte, ok bool) {
+	var parts [3][]byte
+	n := 0
+	for i := 0; i < len(request); {
+		c := request[i]
+		if c == ' ' {
+			i++
+			continue
+		}
+		if c < 0x21 || c > 0x7e {
+			return nil, nil, nil, false
+		}
+
+		end := i
+		for end < len(request) && request[end] != ' ' {
+			if request[end] < 0x21 || request[end] > 0x7e {
+				return nil, nil, nil, false
+			}
+			end++
+		}
+
+		if n < len(parts) {
+			parts[n] = request[i:end]
+		}
+		n++
+		i = end
+	}
+
+	if n < 3 {
+		return nil, nil, nil, false
+	}
+
+	rest, found := bytes.CutPrefix(parts[1], []byte("https://"))
+	if !found {
+		rest, found = bytes.CutPrefix(parts[1], []byte("http://"))
+	}
+	if !found {
+		return nil, nil, nil, false
+	}
+
+	authorityEnd := bytes.IndexAny(rest, "/?#")
+	if authorityEnd < 0 {
+		authorityEnd = len(rest)
+	}
+	authority := rest[:authorityEnd]
+
+	host = authority
+	if colon := bytes.IndexByte(authority, ':'); colon >= 0 {
+		host = authority[:colon]
+		if _, ok := atoiDigits(authority[colon+1:]); !ok {
+			return nil, nil, nil, false
+		}
+	}
+	if len(host) == 0 || !isSimpleHost(host) {
+		return nil, nil, nil, false
+	}
+
+	path = rest[authorityEnd:]
+	if end := bytes.IndexAny(path, "?#"); end >= 0 {
+		path = path[:end]
+	}
+	if bytes.IndexByte(path, '%') >= 0 {
+		return nil, nil, nil, false
+	}
+
+	return parts[0], host, path, true
+}
+
+// isSimpleHost reports whether the host consists only of letters, digits, dots and hyphens.
+func isSimpleHost(host []byte) bool {
+	for _, c := range host {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
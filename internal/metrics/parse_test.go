@@ -1,9 +1,14 @@
 package metrics
 
 import (
+	"math"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseALBLogLine(t *testing.T) {
@@ -68,3 +73,296 @@ func TestParseALBLogLine(t *testing.T) {
 		})
 	}
 }
+
+func TestParseALBLogLine_ErrorReasons(t *testing.T) {
+	valid := testLogLine("GET", "http://api.example.com/users/1")
+
+	tests := []struct {
+		name   string
+		line   string
+		reason string
+	}{
+		{name: "missing fields", line: "http 2024-01-15T10:00:00.000000Z app/lb", reason: parseErrorMissingFields},
+		{name: "bad timestamp", line: strings.Replace(valid, "2024-01-15T10:00:00.000000Z", "yesterday", 1), reason: parseErrorTimestamp},
+		{name: "bad status", line: strings.Replace(valid, " 200 200 ", " - 200 ", 1), reason: parseErrorStatus},
+		{name: "bad target processing time", line: strings.Replace(valid, " 0.001 ", " fast ", 1), reason: parseErrorTargetProcessingTime},
+		{name: "bad request processing time", line: strings.Replace(valid, " 0.000 0.001 ", " slow 0.001 ", 1), reason: parseErrorProcessingTime},
+		{name: "bad bytes", line: strings.Replace(valid, " 218 587 ", " 218 lots ", 1), reason: parseErrorBytes},
+		{name: "bad request", line: strings.Replace(valid, `"GET http://api.example.com/users/1 HTTP/1.1"`, `"-"`, 1), reason: parseErrorRequest},
+		{name: "bad quote", line: strings.Replace(valid, `"Mozilla/5.0"`, `"Mozilla/5.0`, 1), reason: parseErrorQuote},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseALBLogLine(tt.line)
+
+			require.Error(t, err)
+			assert.Equal(t, tt.reason, parseErrorReason(err))
+		})
+	}
+}
+
+func TestSplitALBLogFields(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   []string
+		reason string
+	}{
+		{line: `a b c`, want: []string{"a", "b", "c"}},
+		{line: `a "b c" d`, want: []string{"a", "b c", "d"}},
+		{line: `"say ""hi""" x`, want: []string{`say "hi"`, "x"}},
+		{line: `a  b`, want: []string{"a", "", "b"}},
+		{line: `a `, want: []string{"a", ""}},
+		{line: `"" -`, want: []string{"", "-"}},
+		{line: `a"b c`, reason: parseErrorQuote},
+		{line: `"a b`, reason: parseErrorQuote},
+		{line: `"a"b c`, reason: parseErrorQuote},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := splitALBLogFields(tt.line, nil)
+
+			if tt.reason != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.reason, parseErrorReason(err))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func FuzzParseALBLogLine(f *testing.F) {
+	f.Add(testLogLine("GET", "http://api.example.com/users/1"))
+	f.Add(testFields("GET", "example.com", "/users/123").String())
+	f.Add(`http 2024-01-15T10:00:00.000000Z a b c -1 -1 -1 - - 0 0 "GET  http://x/""y  HTTP/1.1"`)
+
+	f.Fuzz(func(t *testing.T, line string) {
+		entry, err := parseALBLogLine(line)
+		if err != nil {
+			assert.NotEqual(t, parseErrorMalformed, parseErrorReason(err), "every parse error carries a reason")
+			return
+		}
+
+		assert.False(t, entry.timestamp.IsZero())
+	})
+}
+
+func FuzzParseALBLogLineRoundTrip(f *testing.F) {
+	f.Add("GET", "example.com", "/users/123", 200, 2, int64(1705312800123456))
+	f.Add("POST", "admin.example.com", "/", 503, -1, int64(0))
+	f.Add("DELETE", "a-b.example", "/a/b;c=d", 404, 10000, int64(253402300799999999))
+
+	f.Fuzz(func(t *testing.T, method, host, path string, status, targetMillis int, micros int64) {
+		if !isFuzzToken(method, "") || !isSimpleHost([]byte(host)) || host == "" ||
+			!strings.HasPrefix(path, "/") || !isFuzzToken(path, `%?#`) ||
+			status < 0 || status > 999 || targetMillis < -1 || micros < 0 || micros > 253402300799999999 {
+			return
+		}
+
+		timestamp := time.UnixMicro(micros).UTC()
+		fields := testFields(method, host, path)
+		fields.Time = timestamp.Format("2006-01-02T15:04:05.000000Z")
+		fields.ELBStatusCode = status
+		fields.TargetProcessingTime = float64(targetMillis) / 1000
+		if targetMillis < 0 {
+			fields.TargetProcessingTime = -1
+		}
+
+		entry, err := parseALBLogLine(fields.String())
+		require.NoError(t, err)
+
+		assert.True(t, timestamp.Equal(entry.timestamp), "timestamp: want %s, got %s", timestamp, entry.timestamp)
+		assert.Equal(t, method, entry.method)
+		assert.Equal(t, host, entry.host)
+		assert.Equal(t, path, entry.path)
+		assert.Equal(t, status, entry.status)
+		assert.Equal(t, fields.TargetProcessingTime, entry.targetProcessingTime)
+	})
+}
+
+// isFuzzToken reports whether s is non-empty printable ASCII without spaces, quotes or any byte in exclude.
+func isFuzzToken(s, exclude string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if c < 0x21 || c > 0x7e || c == '"' || strings.IndexByte(exclude, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestALBLogParser_MatchesParseALBLogLine(t *testing.T) {
+	valid := testLogLine("GET", "http://api.example.com/users/1")
+
+	lines := []string{
+		valid,
+		testLogLine("POST", "https://api.example.com:443/api/orders?id=1#top"),
+		testLogLine("GET", "http://api.example.com"),
+		testLogLine("GET", "http://api.example.com?q=1"),
+		testLogLine("GET", "http://api.example.com/caf%C3%A9"),
+		testLogLine("GET", "http://[2001:db8::1]:8080/v6"),
+		testLogLine("GET", "http://user@api.example.com/login"),
+		testLogLine("GET", "ftp://api.example.com/file"),
+		testLogLine("GET", "/relative"),
+		strings.Replace(valid, "2024-01-15T10:00:00.000000Z", "2024-01-15T10:00:00Z", 1),
+		strings.Replace(valid, "2024-01-15T10:00:00.000000Z", "2024-01-15T10:00:00.123456789Z", 1),
+		strings.Replace(valid, "2024-01-15T10:00:00.000000Z", "2024-01-15T10:00:00+09:00", 1),
+		strings.Replace(valid, "2024-01-15T10:00:00.000000Z", "2024-02-30T10:00:00.000000Z", 1),
+		strings.Replace(valid, " 0.001 ", " -1 ", 1),
+		strings.Replace(valid, " 0.001 ", " 1e-3 ", 1),
+		strings.Replace(valid, " 0.001 ", " fast ", 1),
+		strings.Replace(valid, " 200 200 ", " - 200 ", 1),
+		strings.Replace(valid, " 200 200 ", " +200 200 ", 1),
+		strings.Replace(valid, " 218 587 ", " - - ", 1),
+		strings.Replace(valid, "203.0.113.10:80 0.000 0.001 0.000", "- -1 -1 -1", 1),
+		strings.Replace(valid, " 218 587 ", " 218 12345678901 ", 1),
+		strings.Replace(valid, " 218 587 ", " 218 big ", 1),
+		strings.Replace(valid, "forward - - - - - - -", `"waf,forward" - "TargetConnectionError" - - "Ambiguous" "SpaceInUri" -`, 1),
+		strings.TrimSuffix(valid, " - - - - - - -"),
+		strings.TrimSuffix(valid, " - -"),
+		valid + " ",
+		strings.Replace(valid, `"GET http://api.example.com/users/1 HTTP/1.1"`, `"-"`, 1),
+		strings.Replace(valid, `"GET http://api.example.com/users/1 HTTP/1.1"`, `"GET  http://api.example.com/a""b  HTTP/1.1"`, 1),
+		strings.Replace(valid, `"GET http://api.example.com/users/1 HTTP/1.1"`, "\"GET\thttp://api.example.com/tab HTTP/1.1\"", 1),
+		"http 2024-01-15T10:00:00.000000Z app/lb",
+		`http "2024-01-15T10:00:00.000000Z`,
+	}
+
+	parser := newALBLogParser()
+	for _, line := range lines {
+		t.Run(line, func(t *testing.T) {
+			want, wantErr := parseALBLogLine(line)
+			got, err := parser.parse([]byte(line))
+
+			if wantErr != nil {
+				require.Error(t, err)
+				assert.Equal(t, parseErrorReason(wantErr), parseErrorReason(err))
+				return
+			}
+
+			require.NoError(t, err)
+			assertSameEntry(t, *want, got)
+		})
+	}
+}
+
+func TestParseClientAddr(t *testing.T) {
+	tests := map[string]string{
+		"198.51.100.100:57832":  "198.51.100.100",
+		"2001:db8::1:57832":     "2001:db8::1",
+		"[2001:db8::1]:57832":   "2001:db8::1",
+		"[::ffff:10.0.0.1]:443": "10.0.0.1",
+		"198.51.100.100":        "198.51.100.100",
+		"010.0.0.1:443":         "",
+		"256.0.0.1:443":         "",
+		"-":                     "",
+		"":                      "",
+	}
+
+	for field, want := range tests {
+		var wantAddr netip.Addr
+		if want != "" {
+			wantAddr = netip.MustParseAddr(want)
+		}
+
+		assert.Equal(t, wantAddr, parseClientAddr(field), field)
+		assert.Equal(t, wantAddr, parseClientAddrBytes([]byte(field)), field)
+	}
+}
+
+func TestALBLogParser_DoesNotAllocate(t *testing.T) {
+	line := []byte(testLogLine("GET", "https://api.example.com:443/users/123?expand=true"))
+	parser := newALBLogParser()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := parser.parse(line); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	assert.Zero(t, allocs)
+}
+
+func FuzzALBLogParser(f *testing.F) {
+	f.Add(testLogLine("GET", "http://api.example.com/users/1"))
+	f.Add(testLogLine("POST", "https://api.example.com:443/api/orders?id=1"))
+	f.Add(testLogLine("GET", "http://[::1]/a%20b"))
+	f.Add(`http 2024-01-15T10:00:00.000000Z a b c -1 -1 -1 - - 0 0 "GET  http://x/""y  HTTP/1.1"`)
+	f.Add("http 2024-01-15T10:00:00.000000Z app/lb")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		got, err := newALBLogParser().parse([]byte(line))
+
+		// The fast parser stops after the request field, so only lines the reference splitter accepts are comparable.
+		if _, splitErr := splitALBLogFields(line, nil); splitErr != nil {
+			return
+		}
+
+		want, wantErr := parseALBLogLine(line)
+		if wantErr != nil {
+			require.Error(t, err)
+			assert.Equal(t, parseErrorReason(wantErr), parseErrorReason(err))
+			return
+		}
+
+		require.NoError(t, err)
+		assertSameEntry(t, *want, got)
+	})
+}
+
+func assertSameEntry(t *testing.T, want, got albLogEntry) {
+	t.Helper()
+
+	assert.True(t, want.timestamp.Equal(got.timestamp), "timestamp: want %s, got %s", want.timestamp, got.timestamp)
+	assert.Equal(t, want.method, got.method)
+	assert.Equal(t, want.host, got.host)
+	assert.Equal(t, want.path, got.path)
+	assert.Equal(t, want.status, got.status)
+	assert.Equal(t, want.clientAddr, got.clientAddr)
+	assert.Equal(t, want.target, got.target)
+	assert.Equal(t, want.requestProcessingTime, got.requestProcessingTime)
+	assert.Equal(t, want.responseProcessingTime, got.responseProcessingTime)
+	assert.Equal(t, want.receivedBytes, got.receivedBytes)
+	assert.Equal(t, want.sentBytes, got.sentBytes)
+	assert.Equal(t, want.userAgent, got.userAgent)
+	assert.Equal(t, want.sslCipher, got.sslCipher)
+	assert.Equal(t, want.sslProtocol, got.sslProtocol)
+	assert.Equal(t, want.actionsExecuted, got.actionsExecuted)
+	assert.Equal(t, want.errorReason, got.errorReason)
+	assert.Equal(t, want.classification, got.classification)
+	assert.Equal(t, want.classificationReason, got.classificationReason)
+	if math.IsNaN(want.targetProcessingTime) {
+		assert.True(t, math.IsNaN(got.targetProcessingTime))
+	} else {
+		assert.Equal(t, want.targetProcessingTime, got.targetProcessingTime)
+	}
+}
+
+func BenchmarkParseALBLogLine(b *testing.B) {
+	line := testLogLine("GET", "https://api.example.com:443/users/123?expand=true")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parseALBLogLine(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkALBLogParser(b *testing.B) {
+	line := []byte(testLogLine("GET", "https://api.example.com:443/users/123?expand=true"))
+	parser := newALBLogParser()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parser.parse(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package metrics
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // pathRuleConfig represents the JSON shape used to configure path normalization rules.
@@ -13,6 +15,14 @@ type pathRuleConfig struct {
 	Pattern string `json:"pattern"`
 	Name    string `json:"name"`
 	Method  string `json:"method,omitempty"`
+	// Metrics selects the metrics published for the rule instead of the default selection.
+	Metrics []metricSpec `json:"metrics,omitempty"`
+	// TargetDimensionUntil also publishes the rule's metrics per target:port until this RFC 3339 time.
+	TargetDimensionUntil string `json:"target_dimension_until,omitempty"`
+	// ClientType also publishes the rule's metrics per client type classified from the user agent.
+	ClientType bool `json:"client_type,omitempty"`
+	// NetworkDimensions also publishes the rule's metrics per Country, ASN or Network of the client.
+	NetworkDimensions []string `json:"network_dimensions,omitempty"`
 }
 
 // pathRules holds the compiled rule set for host-aware path normalization.
@@ -27,6 +37,14 @@ type compiledRule struct {
 	method string
 	name   string
 	regex  *regexp.Regexp
+	// metrics is nil when the rule uses the default selection.
+	metrics []metricSpec
+	// targetUntil is the end of the per-target breakdown, zero when it is disabled.
+	targetUntil time.Time
+	// clientType publishes a per-client type breakdown.
+	clientType bool
+	// networkDimensions lists the client network breakdowns, each published separately.
+	networkDimensions []string
 }
 
 // NewPathRules parses the JSON configuration string and returns a compiled rule set.
@@ -67,11 +85,36 @@ func NewPathRules(raw string) (*pathRules, error) {
 			return nil, fmt.Errorf("path rule %d: failed to compile pattern regex: %w", idx, err)
 		}
 
+		if cfg.Metrics != nil {
+			if len(cfg.Metrics) == 0 {
+				return nil, fmt.Errorf("path rule %d: metrics must not be empty", idx)
+			}
+			if err := validateMetricSpecs(cfg.Metrics); err != nil {
+				return nil, fmt.Errorf("path rule %d: %w", idx, err)
+			}
+		}
+
+		if err := validateNetworkDimensions(cfg.NetworkDimensions); err != nil {
+			return nil, fmt.Errorf("path rule %d: %w", idx, err)
+		}
+
+		var targetUntil time.Time
+		if cfg.TargetDimensionUntil != "" {
+			targetUntil, err = time.Parse(time.RFC3339, cfg.TargetDimensionUntil)
+			if err != nil {
+				return nil, fmt.Errorf("path rule %d: failed to parse target_dimension_until: %w", idx, err)
+			}
+		}
+
 		compiled = append(compiled, compiledRule{
-			host:   cfg.Host,
-			method: method,
-			name:   cfg.Name,
-			regex:  regex,
+			host:              cfg.Host,
+			method:            method,
+			name:              cfg.Name,
+			regex:             regex,
+			metrics:           cfg.Metrics,
+			targetUntil:       targetUntil,
+			clientType:        cfg.ClientType,
+			networkDimensions: cfg.NetworkDimensions,
 		})
 	}
 
@@ -81,13 +124,28 @@ func NewPathRules(raw string) (*pathRules, error) {
 	}, nil
 }
 
+// targetDimension reports whether the rule publishes per-target metrics at now.
+func (r *compiledRule) targetDimension(now time.Time) bool {
+	return now.Before(r.targetUntil)
+}
+
 // normalize returns the configured name for the provided entry if any rule matches.
 func (pr *pathRules) normalize(entry albLogEntry) (string, bool) {
-	if pr == nil || !pr.enabled {
+	idx, ok := pr.match(entry)
+	if !ok {
 		return "", false
 	}
 
-	for _, rule := range pr.rules {
+	return pr.rules[idx].name, true
+}
+
+// match returns the index of the first rule that matches the provided entry.
+func (pr *pathRules) match(entry albLogEntry) (int, bool) {
+	if pr == nil || !pr.enabled {
+		return -1, false
+	}
+
+	for idx, rule := range pr.rules {
 		if entry.host != rule.host {
 			continue
 		}
@@ -97,11 +155,107 @@ func (pr *pathRules) normalize(entry albLogEntry) (string, bool) {
 		}
 
 		if rule.regex.MatchString(entry.path) {
-			return rule.name, true
+			return idx, true
 		}
 	}
 
-	return "", false
+	return -1, false
+}
+
+// PathRuleMatch reports which rule, if any, matched a single request.
+type PathRuleMatch struct {
+	Method string
+	Host   string
+	Path   string
+	// Rule is the index of the matching rule, or -1 when no rule matched.
+	Rule int
+	Name string
+}
+
+// MatchRequest runs a method and absolute URL through the rule set.
+func (pr *pathRules) MatchRequest(method, rawURL string) (PathRuleMatch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return PathRuleMatch{}, fmt.Errorf("parse URL %q: %w", rawURL, err)
+	}
+
+	return pr.matchEntry(albLogEntry{method: method, host: u.Hostname(), path: u.Path}), nil
+}
+
+// MatchLogLine runs a raw ALB access log line through the rule set.
+func (pr *pathRules) MatchLogLine(line string) (PathRuleMatch, error) {
+	entry, err := parseALBLogLine(line)
+	if err != nil {
+		return PathRuleMatch{}, err
+	}
+
+	return pr.matchEntry(*entry), nil
+}
+
+func (pr *pathRules) matchEntry(entry albLogEntry) PathRuleMatch {
+	result := PathRuleMatch{Method: entry.method, Host: entry.host, Path: entry.path, Rule: -1}
+	if idx, ok := pr.match(entry); ok {
+		result.Rule = idx
+		result.Name = pr.rules[idx].name
+	}
+	return result
+}
+
+// PublishedPath describes the path metrics published for a rule name, for tools that build dashboards and alarms on them.
+type PublishedPath struct {
+	Host string
+	// Method is empty when the rule matches any method, so its metrics are published per method seen.
+	Method string
+	Path   string
+	// Metrics maps each published metric name to the metric it publishes, so a metric selected twice,
+	// such as TargetResponseTime in Seconds and in Milliseconds, appears under both names.
+	Metrics map[string]PublishedMetric
+}
+
+// PublishedMetric is a built-in metric, such as TargetResponseTime, and the name and unit it is published with.
+type PublishedMetric struct {
+	Metric string
+	Name   string
+	Unit   string
+}
+
+// PublishedPaths lists the paths the rules publish metrics for, once per host, method and name. Rules
+// without their own selection publish defaults, or the default metrics when defaults is nil.
+func (pr *pathRules) PublishedPaths(defaults []MetricSpec) []PublishedPath {
+	if pr == nil || !pr.enabled {
+		return nil
+	}
+
+	var paths []PublishedPath
+	seen := make(map[pathKey]bool)
+	for _, rule := range pr.rules {
+		key := pathKey{Method: rule.method, Host: rule.host, Path: rule.name}
+		if seen[key] {
+			// The selection of the first rule applies to every rule publishing the same metrics.
+			continue
+		}
+		seen[key] = true
+
+		specs := rule.metrics
+		switch {
+		case specs != nil:
+		case defaults != nil:
+			specs = defaults
+		default:
+			specs = defaultMetricSpecs
+		}
+		published := make(map[string]PublishedMetric, len(specs))
+		for _, spec := range specs {
+			_, unit, _, err := spec.resolve()
+			if err != nil {
+				continue
+			}
+			published[spec.name()] = PublishedMetric{Metric: spec.Metric, Name: spec.name(), Unit: string(unit)}
+		}
+
+		paths = append(paths, PublishedPath{Host: rule.host, Method: rule.method, Path: rule.name, Metrics: published})
+	}
+	return paths
 }
 
 // PathRuleConfig exposes the internal rule configuration structure for tests.
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+const (
+	// LintShadowedRule reports a rule that can never match because an earlier rule wins first.
+	LintShadowedRule = "shadowed"
+	// LintUnanchoredPattern reports a pattern missing a leading ^ or trailing $.
+	LintUnanchoredPattern = "unanchored"
+	// LintDuplicateName reports a name reused for a different host/method pair.
+	LintDuplicateName = "duplicate-name"
+	// LintMethodCase reports a method that is not written in upper case.
+	LintMethodCase = "method-case"
+	// LintTargetDimension reports a per-target breakdown that has expired or runs for longer than a week.
+	LintTargetDimension = "target-dimension"
+
+	// maxTargetDimensionWindow is how far ahead target_dimension_until can be before it is reported.
+	maxTargetDimensionWindow = 7 * 24 * time.Hour
+
+	maxLintSamples = 256
+)
+
+// RuleLintIssue describes a single problem found in a rule set.
+type RuleLintIssue struct {
+	Rule    int
+	Name    string
+	Kind    string
+	Message string
+}
+
+func (i RuleLintIssue) String() string {
+	return fmt.Sprintf("rule %d (%s): %s: %s", i.Rule, i.Name, i.Kind, i.Message)
+}
+
+// LintPathRules parses the JSON rule configuration and reports rules that are valid but likely mistaken.
+func LintPathRules(raw string) ([]RuleLintIssue, error) {
+	rules, err := NewPathRules(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rules.enabled {
+		return nil, nil
+	}
+
+	var configs []pathRuleConfig
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse path rules JSON: %w", err)
+	}
+
+	var issues []RuleLintIssue
+	report := func(idx int, kind, format string, args ...any) {
+		issues = append(issues, RuleLintIssue{
+			Rule:    idx,
+			Name:    configs[idx].Name,
+			Kind:    kind,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	firstByName := make(map[string]int, len(configs))
+
+	for idx, cfg := range configs {
+		rule := rules.rules[idx]
+
+		if cfg.Method != rule.method {
+			report(idx, LintMethodCase, "method %q is matched case-insensitively; write it as %q", cfg.Method, rule.method)
+		}
+
+		if !strings.HasPrefix(cfg.Pattern, "^") || !strings.HasSuffix(cfg.Pattern, "$") {
+			report(idx, LintUnanchoredPattern, "pattern %q is not anchored with ^ and $ and matches any path containing it", cfg.Pattern)
+		}
+
+		if first, ok := firstByName[cfg.Name]; ok {
+			prev := rules.rules[first]
+			if prev.host != rule.host || prev.method != rule.method {
+				report(idx, LintDuplicateName, "name is already used by rule %d for %s", first, describeRuleTarget(prev))
+			}
+		} else {
+			firstByName[cfg.Name] = idx
+		}
+
+		if until := rule.targetUntil; !until.IsZero() {
+			now := time.Now()
+			switch {
+			case !rule.targetDimension(now):
+				report(idx, LintTargetDimension, "target_dimension_until %s has passed; remove it", until.Format(time.RFC3339))
+			case until.Sub(now) > maxTargetDimensionWindow:
+				report(idx, LintTargetDimension, "target_dimension_until %s is more than a week away; the Target dimension is meant for short investigations", until.Format(time.RFC3339))
+			}
+		}
+
+		if by, ok := rules.shadowedBy(idx); ok {
+			report(idx, LintShadowedRule, "every sample path for this rule is matched first by rule %d (%s)", by, rules.rules[by].name)
+		}
+	}
+
+	return issues, nil
+}
+
+func describeRuleTarget(rule compiledRule) string {
+	method := rule.method
+	if method == "" {
+		method = "any method"
+	}
+	return fmt.Sprintf("host %q and %s", rule.host, method)
+}
+
+// shadowedBy reports the earlier rule that matches every sample path derived from the rule at idx.
+func (pr *pathRules) shadowedBy(idx int) (int, bool) {
+	rule := pr.rules[idx]
+
+	parsed, err := syntax.Parse(rule.regex.String(), syntax.Perl)
+	if err != nil {
+		return -1, false
+	}
+
+	var samples []string
+	for _, s := range regexSamples(parsed.Simplify(), maxLintSamples) {
+		if rule.regex.MatchString(s) {
+			samples = append(samples, s)
+		}
+	}
+
+	if len(samples) == 0 {
+		return -1, false
+	}
+
+	for prevIdx, prev := range pr.rules[:idx] {
+		if prev.host != rule.host {
+			continue
+		}
+
+		if prev.method != "" && prev.method != rule.method {
+			continue
+		}
+
+		shadowed := true
+		for _, s := range samples {
+			if !prev.regex.MatchString(s) {
+				shadowed = false
+				break
+			}
+		}
+
+		if shadowed {
+			return prevIdx, true
+		}
+	}
+
+	return -1, false
+}
+
+// regexSamples returns up to limit short strings generated from the regular expression tree.
+func regexSamples(re *syntax.Regexp, limit int) []string {
+	if limit <= 0 {
+		return nil
+	}
+
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return nil
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		return charClassSamples(re.Rune)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"x"}
+	case syntax.OpCapture:
+		return regexSamples(re.Sub[0], limit)
+	case syntax.OpStar:
+		return append([]string{""}, repeatSamples(regexSamples(re.Sub[0], limit), 1, true, limit-1)...)
+	case syntax.OpPlus:
+		return repeatSamples(regexSamples(re.Sub[0], limit), 1, true, limit)
+	case syntax.OpQuest:
+		return append([]string{""}, regexSamples(re.Sub[0], limit-1)...)
+	case syntax.OpRepeat:
+		samples := repeatSamples(regexSamples(re.Sub[0], limit), max(re.Min, 1), re.Max == -1 || re.Max > max(re.Min, 1), limit)
+		if re.Min == 0 {
+			samples = append([]string{""}, samples...)
+		}
+		return samples
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			samples = crossSamples(samples, regexSamples(sub, limit), limit)
+		}
+		return samples
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			samples = append(samples, regexSamples(sub, limit-len(samples))...)
+			if len(samples) >= limit {
+				return samples[:limit]
+			}
+		}
+		return samples
+	default:
+		// Empty-width assertions such as ^, $ and \b contribute no characters.
+		return []string{""}
+	}
+}
+
+// charClassSamples picks the lowest, middle and highest printable members of every range of a class, so
+// that an earlier rule only shadows a class it covers end to end.
+func charClassSamples(ranges []rune) []string {
+	var samples []string
+	seen := make(map[rune]bool)
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if printable := max(lo, '!'); printable <= hi {
+			lo = printable
+			if lo <= '~' {
+				hi = min(hi, '~')
+			}
+		}
+
+		for _, r := range []rune{lo, lo + (hi-lo)/2, hi} {
+			if !seen[r] {
+				seen[r] = true
+				samples = append(samples, string(r))
+			}
+		}
+	}
+	return samples
+}
+
+// repeatSamples repeats the samples count times, and once more when more repetitions are allowed, so that
+// an earlier rule only shadows a repetition it accepts at more than one length.
+func repeatSamples(sub []string, count int, more bool, limit int) []string {
+	samples := []string{""}
+	for range count {
+		samples = crossSamples(samples, sub, limit)
+	}
+	if more {
+		samples = append(samples, crossSamples(samples, sub, limit-len(samples))...)
+	}
+	return samples
+}
+
+func crossSamples(prefixes, suffixes []string, limit int) []string {
+	samples := make([]string, 0, min(len(prefixes)*len(suffixes), limit))
+	for _, p := range prefixes {
+		for _, s := range suffixes {
+			if len(samples) >= limit {
+				return samples
+			}
+			samples = append(samples, p+s)
+		}
+	}
+	return samples
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintPathRules_IdenticalRuleIsShadowed(t *testing.T) {
+	raw := `[
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"example.com","pattern":"^/users/[0-9]+/posts$","name":"/users/:id/posts","method":"GET"},
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"}
+	]`
+
+	issues, err := LintPathRules(raw)
+
+	require.NoError(t, err)
+	kinds := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	assert.Equal(t, []string{LintShadowedRule}, kinds)
+}
+
+func TestLintPathRules_ReportsProblems(t *testing.T) {
+	raw := `[
+		{"host":"example.com","pattern":"^/users/.*$","name":"/users/*"},
+		{"host":"example.com","pattern":"^/users/(?:[0-9]+|me)$","name":"/users/:id","method":"get"},
+		{"host":"example.com","pattern":"/articles","name":"/articles"},
+		{"host":"admin.example.com","pattern":"^/articles$","name":"/articles"}
+	]`
+
+	issues, err := LintPathRules(raw)
+	require.NoError(t, err)
+
+	got := make(map[int][]string)
+	for _, issue := range issues {
+		got[issue.Rule] = append(got[issue.Rule], issue.Kind)
+	}
+
+	assert.Equal(t, map[int][]string{
+		1: {LintMethodCase, LintShadowedRule},
+		2: {LintUnanchoredPattern},
+		3: {LintDuplicateName},
+	}, got)
+}
+
+func TestLintPathRules_NotShadowedByNarrowerRule(t *testing.T) {
+	raw := `[
+		{"host":"example.com","pattern":"^/users/me$","name":"/users/me"},
+		{"host":"example.com","pattern":"^/users/[a-z0-9]+$","name":"/users/:id"},
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"}
+	]`
+
+	issues, err := LintPathRules(raw)
+	require.NoError(t, err)
+
+	require.Len(t, issues, 2)
+	assert.Equal(t, 2, issues[0].Rule)
+	assert.Equal(t, LintDuplicateName, issues[0].Kind)
+	assert.Equal(t, 2, issues[1].Rule)
+	assert.Equal(t, LintShadowedRule, issues[1].Kind)
+}
+
+func TestLintPathRules_NotShadowedByRuleForOneMember(t *testing.T) {
+	raw := `[
+		{"host":"example.com","pattern":"^/users/0$","name":"/users/0"},
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"},
+		{"host":"example.com","pattern":"^/orders/0+$","name":"/orders/0"},
+		{"host":"example.com","pattern":"^/orders/[0-9]+$","name":"/orders/:id"}
+	]`
+
+	issues, err := LintPathRules(raw)
+
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestLintPathRules_InvalidConfig(t *testing.T) {
+	_, err := LintPathRules(`[{"host":"example.com","pattern":"^/users/[","name":"/users/:id"}]`)
+	assert.Error(t, err)
+}
+
+func TestPathRulesMatchRequest(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id/update"}
+	]`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		method string
+		url    string
+		rule   int
+		name   string
+	}{
+		{method: "GET", url: "https://example.com:443/users/42", rule: 0, name: "/users/:id"},
+		{method: "PUT", url: "https://example.com/users/42", rule: 1, name: "/users/:id/update"},
+		{method: "GET", url: "https://example.com/health", rule: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			got, err := rules.MatchRequest(tt.method, tt.url)
+
+			require.NoError(t, err)
+			assert.Equal(t, "example.com", got.Host)
+			assert.Equal(t, tt.rule, got.Rule)
+			assert.Equal(t, tt.name, got.Name)
+		})
+	}
+}
+
+func TestLintPathRules_TargetDimension(t *testing.T) {
+	now := time.Now().UTC()
+	raw := fmt.Sprintf(`[
+		{"host":"example.com","pattern":"^/a$","name":"/a","target_dimension_until":%q},
+		{"host":"example.com","pattern":"^/b$","name":"/b","target_dimension_until":%q},
+		{"host":"example.com","pattern":"^/c$","name":"/c","target_dimension_until":%q}
+	]`, now.Add(time.Hour).Format(time.RFC3339), now.Add(-time.Hour).Format(time.RFC3339), now.Add(30*24*time.Hour).Format(time.RFC3339))
+
+	issues, err := LintPathRules(raw)
+	require.NoError(t, err)
+
+	require.Len(t, issues, 2)
+	assert.Equal(t, 1, issues[0].Rule)
+	assert.Contains(t, issues[0].Message, "has passed")
+	assert.Equal(t, 2, issues[1].Rule)
+	assert.Equal(t, LintTargetDimension, issues[1].Kind)
+}
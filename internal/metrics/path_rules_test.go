@@ -2,7 +2,10 @@ package metrics
 
 import (
 	"encoding/json"
+	"regexp"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -86,6 +89,47 @@ func TestNewPathRules_InvalidRegex(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestPathRules_PublishedPaths(t *testing.T) {
+	rules, err := NewPathRules(`[
+		{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"example.com","pattern":"^/u/[0-9]+$","name":"/users/:id","method":"GET"},
+		{"host":"example.com","pattern":"^/checkout$","name":"/checkout","metrics":["RequestCount","TargetResponseTime",{"metric":"TargetResponseTime","name":"Latency","unit":"Milliseconds"}]}
+	]`)
+	require.NoError(t, err)
+
+	paths := rules.PublishedPaths(nil)
+	require.Len(t, paths, 2)
+
+	assert.Equal(t, PublishedPath{
+		Host:   "example.com",
+		Method: "GET",
+		Path:   "/users/:id",
+		Metrics: map[string]PublishedMetric{
+			"TargetResponseTime": {Metric: "TargetResponseTime", Name: "TargetResponseTime", Unit: "Seconds"},
+			"RequestCount":       {Metric: "RequestCount", Name: "RequestCount", Unit: "Count"},
+			"FailedRequestCount": {Metric: "FailedRequestCount", Name: "FailedRequestCount", Unit: "Count"},
+		},
+	}, paths[0])
+	assert.Equal(t, PublishedPath{
+		Host: "example.com",
+		Path: "/checkout",
+		Metrics: map[string]PublishedMetric{
+			"RequestCount":       {Metric: "RequestCount", Name: "RequestCount", Unit: "Count"},
+			"TargetResponseTime": {Metric: "TargetResponseTime", Name: "TargetResponseTime", Unit: "Seconds"},
+			"Latency":            {Metric: "TargetResponseTime", Name: "Latency", Unit: "Milliseconds"},
+		},
+	}, paths[1])
+
+	defaults, err := ParseMetricSpecs(`["RequestCount"]`)
+	require.NoError(t, err)
+	paths = rules.PublishedPaths(defaults)
+	assert.Equal(t, map[string]PublishedMetric{"RequestCount": {Metric: "RequestCount", Name: "RequestCount", Unit: "Count"}}, paths[0].Metrics)
+
+	disabled, err := NewPathRules("")
+	require.NoError(t, err)
+	assert.Nil(t, disabled.PublishedPaths(nil))
+}
+
 func TestPathRulesNormalize_Match(t *testing.T) {
 	raw := `[{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`
 
@@ -134,3 +178,64 @@ func TestPathRulesNormalize_Disabled(t *testing.T) {
 	assert.False(t, matched)
 	assert.Empty(t, name)
 }
+
+func FuzzNewPathRules(f *testing.F) {
+	f.Add(`[{"host":"example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`, "GET", "example.com", "/users/42")
+	f.Add(`[{"host":"example.com","pattern":"^/$","name":"/","method":"get"}]`, "GET", "example.com", "/")
+	f.Add(`[{"host":"example.com","pattern":"(","name":"x"}]`, "GET", "example.com", "/")
+	f.Add(`[]`, "GET", "example.com", "/")
+
+	f.Fuzz(func(t *testing.T, raw, method, host, path string) {
+		rules, err := NewPathRules(raw)
+		if err != nil {
+			return
+		}
+
+		entry := albLogEntry{method: method, host: host, path: path}
+		name, matched := rules.normalize(entry)
+		idx, ok := rules.match(entry)
+
+		assert.Equal(t, ok, matched)
+		if matched {
+			assert.Equal(t, rules.rules[idx].name, name)
+		}
+	})
+}
+
+func FuzzPathRulesNormalize(f *testing.F) {
+	f.Add("example.com", "^/users/[0-9]+$", "/users/:id", "", "GET", "example.com", "/users/42")
+	f.Add("example.com", "^/users/[0-9]+$", "/users/:id", "post", "POST", "example.com", "/users/42")
+	f.Add("example.com", "/orders", "/orders", "", "GET", "api.example.com", "/orders")
+	f.Add("example.com", "^/$", "/", "DELETE", "GET", "example.com", "/")
+
+	f.Fuzz(func(t *testing.T, ruleHost, pattern, ruleName, ruleMethod, method, host, path string) {
+		// JSON replaces invalid UTF-8, so such rules would not round-trip through the config.
+		for _, s := range []string{ruleHost, pattern, ruleName, ruleMethod} {
+			if !utf8.ValidString(s) {
+				return
+			}
+		}
+
+		raw, err := json.Marshal([]pathRuleConfig{{Host: ruleHost, Pattern: pattern, Name: ruleName, Method: ruleMethod}})
+		require.NoError(t, err)
+
+		rules, err := NewPathRules(string(raw))
+		if err != nil {
+			return
+		}
+
+		// A single rule matches exactly when host, method and pattern all agree.
+		regex := regexp.MustCompile(pattern)
+		want := host == ruleHost &&
+			(ruleMethod == "" || strings.EqualFold(method, strings.ToUpper(ruleMethod))) &&
+			regex.MatchString(path)
+
+		name, matched := rules.normalize(albLogEntry{method: method, host: host, path: path})
+		assert.Equal(t, want, matched)
+		if matched {
+			assert.Equal(t, ruleName, name)
+		} else {
+			assert.Empty(t, name)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	metricNameObjectsProcessed      = "ObjectsProcessed"
+	metricNameObjectsSkipped        = "ObjectsSkipped"
+	metricNameCompressedBytesRead   = "CompressedBytesRead"
+	metricNameUncompressedBytesRead = "UncompressedBytesRead"
+	metricNameLinesRead             = "LinesRead"
+	metricNameLinesMatched          = "LinesMatched"
+	metricNameDatumsPublished       = "DatumsPublished"
+	metricNamePutMetricDataCalls    = "PutMetricDataCalls"
+	metricNamePutMetricDataFailures = "PutMetricDataFailures"
+	metricNameProcessingDuration    = "ProcessingDuration"
+	metricNameLogDeliveryLag        = "LogDeliveryLag"
+)
+
+// pipelineStats holds operational counters for a single invocation.
+type pipelineStats struct {
+	objects           int
+	skippedObjects    int
+	compressedBytes   int64
+	uncompressedBytes int64
+	lines             int
+	matchedLines      int
+	newestEntry       time.Time
+}
+
+// observeEntry tracks the newest log entry timestamp seen so far.
+func (s *pipelineStats) observeEntry(entry albLogEntry) {
+	if entry.timestamp.After(s.newestEntry) {
+		s.newestEntry = entry.timestamp
+	}
+}
+
+// metricData materializes the invocation counters together with the publisher counters.
+func (s *pipelineStats) metricData(publisher *cloudWatchMetricPublisher, duration time.Duration, now time.Time) []types.MetricDatum {
+	datum := func(name string, value float64, unit types.StandardUnit) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Timestamp:  aws.Time(now),
+			Value:      aws.Float64(value),
+			Unit:       unit,
+		}
+	}
+
+	metricData := []types.MetricDatum{
+		datum(metricNameObjectsProcessed, float64(s.objects), types.StandardUnitCount),
+		datum(metricNameObjectsSkipped, float64(s.skippedObjects), types.StandardUnitCount),
+		datum(metricNameCompressedBytesRead, float64(s.compressedBytes), types.StandardUnitBytes),
+		datum(metricNameUncompressedBytesRead, float64(s.uncompressedBytes), types.StandardUnitBytes),
+		datum(metricNameLinesRead, float64(s.lines), types.StandardUnitCount),
+		datum(metricNameLinesMatched, float64(s.matchedLines), types.StandardUnitCount),
+		datum(metricNameDatumsPublished, float64(publisher.datumsPublished), types.StandardUnitCount),
+		datum(metricNamePutMetricDataCalls, float64(publisher.putCalls), types.StandardUnitCount),
+		datum(metricNamePutMetricDataFailures, float64(publisher.putFailures), types.StandardUnitCount),
+		datum(metricNameProcessingDuration, duration.Seconds(), types.StandardUnitSeconds),
+	}
+
+	// Lag is only meaningful once at least one entry has been parsed.
+	if !s.newestEntry.IsZero() {
+		metricData = append(metricData, datum(metricNameLogDeliveryLag, now.Sub(s.newestEntry).Seconds(), types.StandardUnitSeconds))
+	}
+
+	return metricData
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineStats_MetricData(t *testing.T) {
+	stats := pipelineStats{
+		objects:           2,
+		skippedObjects:    1,
+		compressedBytes:   100,
+		uncompressedBytes: 1000,
+		lines:             10,
+		matchedLines:      4,
+	}
+	stats.observeEntry(albLogEntry{timestamp: parseTime(t, "2024-01-15T10:04:00Z")})
+	stats.observeEntry(albLogEntry{timestamp: parseTime(t, "2024-01-15T10:05:00Z")})
+	stats.observeEntry(albLogEntry{timestamp: parseTime(t, "2024-01-15T10:03:00Z")})
+
+	publisher := &cloudWatchMetricPublisher{datumsPublished: 40, putCalls: 3, putFailures: 1}
+	now := parseTime(t, "2024-01-15T10:10:00Z")
+
+	got := make(map[string]float64)
+	for _, datum := range stats.metricData(publisher, 1500*time.Millisecond, now) {
+		got[aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+		assert.Equal(t, now, aws.ToTime(datum.Timestamp))
+	}
+
+	assert.Equal(t, map[string]float64{
+		metricNameObjectsProcessed:      2,
+		metricNameObjectsSkipped:        1,
+		metricNameCompressedBytesRead:   100,
+		metricNameUncompressedBytesRead: 1000,
+		metricNameLinesRead:             10,
+		metricNameLinesMatched:          4,
+		metricNameDatumsPublished:       40,
+		metricNamePutMetricDataCalls:    3,
+		metricNamePutMetricDataFailures: 1,
+		metricNameProcessingDuration:    1.5,
+		metricNameLogDeliveryLag:        300,
+	}, got)
+}
+
+func TestPipelineStats_NoEntriesOmitsLag(t *testing.T) {
+	var stats pipelineStats
+
+	for _, datum := range stats.metricData(&cloudWatchMetricPublisher{}, time.Second, time.Now()) {
+		assert.NotEqual(t, metricNameLogDeliveryLag, aws.ToString(datum.MetricName))
+	}
+}
+
+func TestProcessLines_UpdatesPipelineStats(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"api.example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`)
+	require.NoError(t, err)
+	processor := NewProcwessor(nil, nil, rules, ProcessorOptions{})
+
+	lines := strings.Join([]string{
+		testLogLine("GET", "http://api.example.com/users/1"),
+		testLogLine("GET", "http://api.example.com/health"),
+		"invalid",
+	}, "\n")
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err = gz.Write([]byte(lines))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	compressedSize := int64(buf.Len())
+
+	body := countingReader{r: &buf, n: &processor.stats.compressedBytes}
+	gzipReader, err := gzip.NewReader(body)
+	require.NoError(t, err)
+
+	_, err = processor.processLines(countingReader{r: gzipReader, n: &processor.stats.uncompressedBytes})
+	require.NoError(t, err)
+
+	assert.Equal(t, compressedSize, processor.stats.compressedBytes)
+	assert.Equal(t, int64(len(lines)), processor.stats.uncompressedBytes)
+	assert.Equal(t, 3, processor.stats.lines)
+	assert.Equal(t, 1, processor.stats.matchedLines)
+	assert.Equal(t, parseTime(t, "2024-01-15T10:00:00Z"), processor.stats.newestEntry)
+}
@@ -1,43 +1,179 @@
 package metrics
 
 import (
-	"bufio"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/url"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/klauspost/compress/zstd"
 )
 
+// Namespace is the CloudWatch namespace path metrics are published to.
+const Namespace = "ALBAccessLog"
+
+// ObjectGetter is the subset of the S3 API used to read ALB log objects.
+type ObjectGetter interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// ProcessorOptions configures optional Processor behavior.
+type ProcessorOptions struct {
+	// DryRun skips PutMetricData calls.
+	DryRun bool
+	// Logger receives structured logs; every published metric datum is logged at debug level.
+	// Defaults to slog.Default().
+	Logger *slog.Logger
+	// Coverage tracks matched and unmatched lines for CoverageReport.
+	Coverage bool
+	// PublishUnmatchedCount publishes UnmatchedRequestCount per host.
+	PublishUnmatchedCount bool
+	// ParseErrorThreshold fails the invocation when the fraction of unparsable lines in an object exceeds it.
+	// Zero disables the check.
+	ParseErrorThreshold float64
+	// ParseErrorSamples is the number of unparsable lines logged per object.
+	ParseErrorSamples int
+	// MaxLineBytes is the longest log line that is parsed; longer lines are skipped and counted as LineTooLong.
+	// Defaults to 1 MiB.
+	MaxLineBytes int
+	// KeyPrefix skips objects whose key does not start with it. Empty accepts every key.
+	KeyPrefix string
+	// Metrics selects the path metrics published for rules without their own selection.
+	// Defaults to TargetResponseTime, RequestCount and FailedRequestCount.
+	Metrics []MetricSpec
+	// MaxTargets is the number of distinct Target values published per Method/Host/Path by rules with
+	// target_dimension_until; later targets are published as Other. Defaults to 20.
+	MaxTargets int
+	// UnavailableAsFailed counts requests whose target never responded as FailedRequestCount, even when
+	// ALB logged a status below 500 for them.
+	UnavailableAsFailed bool
+	// ClientTypes classifies user agents for rules with client_type. Nil disables the breakdown.
+	ClientTypes *ClientClassifier
+	// ClientNetworks looks up client addresses for rules with network_dimensions. Nil disables the breakdown.
+	ClientNetworks *ClientNetworks
+	// Exemplars is the number of slowest requests, and of sampled failed requests, logged per
+	// Method/Host/Path and minute with their trace ID. Zero disables exemplars.
+	Exemplars int
+	// SLOs publishes good and total request counts per SLO.
+	SLOs []SLO
+	// TLSMetrics publishes TLSRequestCount per host, TLS protocol and cipher for every parsed request.
+	TLSMetrics bool
+	// TLSLegacyOnly restricts TLSRequestCount to requests that did not negotiate TLS 1.3.
+	TLSLegacyOnly bool
+	// PipelineNamespace enables operational metrics about the processor itself, published to this namespace.
+	PipelineNamespace string
+}
+
 type Processor struct {
-	s3Client   *s3.Client
+	s3Client   ObjectGetter
 	rules      *pathRules
 	aggregator *metricAggregator
 	publisher  *cloudWatchMetricPublisher
-	debug      bool
+	coverage   *coverageTracker
+	logger     *slog.Logger
+	opts       ProcessorOptions
+
+	pipelinePublisher *cloudWatchMetricPublisher
+	stats             pipelineStats
+	exemplars         *exemplarRecorder
+	// slos holds the SLOs of each rule name.
+	slos map[string][]*slo
+
+	parser *albLogParser
+	now    func() time.Time
+	// segmentBuf is reused by segments across log lines.
+	segmentBuf []segment
 }
 
-func NewProcwessor(s3Client *s3.Client, cwClient *cloudwatch.Client, rules *pathRules, dryRun, debug bool) *Processor {
-	return &Processor{
-		s3Client:   s3Client,
-		rules:      rules,
-		aggregator: &metricAggregator{metrics: make(map[metricKey]*metricAggregate)},
+func NewProcwessor(s3Client ObjectGetter, cwClient MetricPutter, rules *pathRules, opts ProcessorOptions) *Processor {
+	p := &Processor{
+		s3Client: s3Client,
+		rules:    rules,
+		aggregator: &metricAggregator{
+			metrics:             make(map[metricKey]*metricAggregate),
+			defaultMetrics:      opts.Metrics,
+			unavailableAsFailed: opts.UnavailableAsFailed,
+			maxTargets:          opts.MaxTargets,
+			tlsLegacyOnly:       opts.TLSLegacyOnly,
+		},
 		publisher: &cloudWatchMetricPublisher{
 			client:       cwClient,
-			namespace:    "ALBAccessLog",
+			namespace:    Namespace,
 			maxBatchSize: defaultMetricBatchSize,
-			dryRun:       dryRun,
+			dryRun:       opts.DryRun,
 		},
-		debug: debug,
+		logger: opts.Logger,
+		opts:   opts,
+		parser: newALBLogParser(),
+		now:    time.Now,
+	}
+
+	if p.logger == nil {
+		p.logger = slog.Default()
+	}
+
+	if opts.Coverage {
+		p.coverage = newCoverageTracker(rules)
+	}
+
+	if len(opts.SLOs) > 0 {
+		p.slos = slosByPath(opts.SLOs)
 	}
+
+	if opts.Exemplars > 0 {
+		p.exemplars = newExemplarRecorder(opts.Exemplars)
+	}
+
+	if opts.PipelineNamespace != "" {
+		p.pipelinePublisher = &cloudWatchMetricPublisher{
+			client:       cwClient,
+			namespace:    opts.PipelineNamespace,
+			maxBatchSize: defaultMetricBatchSize,
+			dryRun:       opts.DryRun,
+		}
+	}
+
+	if p.opts.ParseErrorSamples <= 0 {
+		p.opts.ParseErrorSamples = defaultParseErrorSamples
+	}
+
+	return p
 }
 
 func (p *Processor) HandleEvent(ctx context.Context, s3Event events.S3Event) error {
+	logger := p.logger
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		logger = logger.With("request_id", lc.AwsRequestID)
+	}
+
+	start := time.Now()
+	err := p.handleEvent(ctx, logger, s3Event)
+
+	if p.pipelinePublisher != nil {
+		now := time.Now().UTC()
+		metricData := p.stats.metricData(p.publisher, now.Sub(start), now)
+		if pubErr := p.pipelinePublisher.publish(ctx, logger, metricData); pubErr != nil {
+			logger.Error("failed to publish pipeline metrics", "error", pubErr)
+		}
+	}
+
+	return err
+}
+
+func (p *Processor) handleEvent(ctx context.Context, logger *slog.Logger, s3Event events.S3Event) error {
+	parseErrors := newParseErrorStats(p.opts.ParseErrorSamples)
+	var thresholdErr error
+
 	for _, record := range s3Event.Records {
 		bucket := record.S3.Bucket.Name
 		if bucket == "" {
@@ -49,83 +185,261 @@ func (p *Processor) HandleEvent(ctx context.Context, s3Event events.S3Event) err
 			return fmt.Errorf("decode object key %q: %w", record.S3.Object.Key, err)
 		}
 
-		if err := p.streamObjectLines(ctx, bucket, key); err != nil {
+		objectLogger := logger.With("bucket", bucket, "key", key)
+		if reason := skipObjectReason(key, p.opts.KeyPrefix); reason != "" {
+			p.stats.skippedObjects++
+			objectLogger.Info("skipping object", "reason", reason)
+			continue
+		}
+
+		stats, encoding, err := p.streamObjectLines(ctx, bucket, key)
+		if errors.Is(err, errNotALBLog) {
+			p.stats.skippedObjects++
+			objectLogger.Warn("skipping object", "reason", err.Error(), "encoding", encoding)
+			continue
+		}
+		if err != nil {
 			return fmt.Errorf("stream s3://%s/%s: %w", bucket, key, err)
 		}
+
+		objectLogger.Info("processed object", "encoding", encoding, "lines", stats.lines, "parse_errors", stats.total())
+		logParseErrors(objectLogger, stats)
+		parseErrors.merge(stats)
+
+		if threshold := p.opts.ParseErrorThreshold; thresholdErr == nil && threshold > 0 && stats.rate() > threshold {
+			thresholdErr = fmt.Errorf("s3://%s/%s: parse error rate %.4f exceeds threshold %.4f", bucket, key, stats.rate(), threshold)
+		}
+	}
+
+	// Nothing is published when the threshold is exceeded so that a retry does not double count the path
+	// metrics or ParseErrorCount; the parse errors of each attempt are still logged above.
+	if thresholdErr != nil {
+		return thresholdErr
 	}
 
 	metricData := p.aggregator.GetCloudWatchMetricData()
+	metricData = append(metricData, parseErrors.metricData(time.Now().UTC())...)
+
 	if len(metricData) == 0 {
 		return nil
 	}
 
-	if err := p.publisher.publish(ctx, metricData); err != nil {
+	if err := p.publisher.publish(ctx, logger, metricData); err != nil {
 		return fmt.Errorf("publish metrics: %w", err)
 	}
 
-	if p.debug {
-		p.logMetrics(metricData)
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		logMetrics(ctx, logger, metricData)
+	}
+
+	if p.exemplars != nil {
+		p.exemplars.log(ctx, logger)
 	}
 
 	return nil
 }
 
-func (p *Processor) streamObjectLines(ctx context.Context, bucket, key string) error {
+// streamObjectLines reads the object, decompressing it based on its content, and aggregates its lines.
+// It returns the detected encoding alongside the parse failures.
+func (p *Processor) streamObjectLines(ctx context.Context, bucket, key string) (*parseErrorStats, string, error) {
 	resp, err := p.s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
 	if err != nil {
-		return fmt.Errorf("get object: %w", err)
+		return nil, "", fmt.Errorf("get object: %w", err)
 	}
 	defer resp.Body.Close()
 
-	gzipReader, err := gzip.NewReader(resp.Body)
+	body := countingReader{r: resp.Body, n: &p.stats.compressedBytes}
+
+	reader, encoding, err := newLogReader(body)
 	if err != nil {
-		return fmt.Errorf("create gzip reader: %w", err)
+		return nil, encoding, err
 	}
-	defer gzipReader.Close()
+	defer reader.Close()
+
+	stats, err := p.processLines(countingReader{r: reader, n: &p.stats.uncompressedBytes})
+	if err != nil {
+		return nil, encoding, fmt.Errorf("scan %s stream: %w", encoding, err)
+	}
+	p.stats.objects++
+
+	return stats, encoding, nil
+}
+
+// ProcessLines aggregates every ALB log line read from r.
+func (p *Processor) ProcessLines(r io.Reader) error {
+	_, err := p.processLines(r)
+	return err
+}
 
-	scanner := bufio.NewScanner(gzipReader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry, name, matched := p.normalizeLogLine(line)
-		if !matched {
+// processLines aggregates every ALB log line read from r and returns the parse failures it encountered.
+// A truncated compressed stream is recorded as a parse failure rather than returned as an error. The object
+// is only rejected with errNotALBLog when none of its first albSniffLines lines look like an ALB log, so a
+// log with a corrupt first line is still processed and its bad lines are counted as parse errors.
+func (p *Processor) processLines(r io.Reader) (*parseErrorStats, error) {
+	stats := newParseErrorStats(p.opts.ParseErrorSamples)
+	now := p.now()
+
+	lines := NewLineReader(r, p.opts.MaxLineBytes)
+	// sniffed holds the lines read before any of them looked like an ALB log.
+	var sniffed []logLine
+	albLog := false
+	for {
+		b, oversized, err := lines.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if !isTruncatedStream(err) {
+				return nil, err
+			}
+			for _, line := range sniffed {
+				p.processLine(stats, line, lines.MaxBytes(), now)
+			}
+			sniffed = nil
+			stats.record(stats.lines+1, "", newParseError(parseErrorTruncatedObject, "truncated object: "+err.Error()))
+			break
+		}
+
+		if oversized == 0 && len(b) == 0 {
 			continue
 		}
-		p.aggregator.Record(*entry, name)
+
+		line := logLine{text: b, oversized: oversized}
+		if !albLog {
+			if oversized > 0 || !looksLikeALBLog(string(b)) {
+				sniffed = append(sniffed, logLine{text: bytes.Clone(b), oversized: oversized})
+				if len(sniffed) == albSniffLines {
+					return nil, errNotALBLog
+				}
+				continue
+			}
+
+			albLog = true
+			for _, line := range sniffed {
+				p.processLine(stats, line, lines.MaxBytes(), now)
+			}
+			sniffed = nil
+		}
+		p.processLine(stats, line, lines.MaxBytes(), now)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("scan gzip stream: %w", err)
+	if len(sniffed) > 0 {
+		return nil, errNotALBLog
 	}
+	return stats, nil
+}
 
-	return nil
+// logLine is a line read from a log object, or the length of a line too long to be read.
+type logLine struct {
+	text      []byte
+	oversized int
 }
 
-func (p *Processor) logMetrics(metricData []types.MetricDatum) {
-	expandDimensions := func(dimensions []types.Dimension) (r []string) {
-		for _, d := range dimensions {
-			r = append(r, fmt.Sprintf("%s=%s", aws.ToString(d.Name), aws.ToString(d.Value)))
+// processLine parses one log line and records it in the aggregator, or records why it could not be parsed.
+func (p *Processor) processLine(stats *parseErrorStats, line logLine, maxBytes int, now time.Time) {
+	stats.lines++
+	p.stats.lines++
+
+	if line.oversized > 0 {
+		stats.record(stats.lines, "", newParseError(parseErrorLineTooLong, fmt.Sprintf("line of %d bytes exceeds the %d byte limit", line.oversized, maxBytes)))
+		return
+	}
+
+	entry, err := p.parser.parse(line.text)
+	if err != nil {
+		stats.record(stats.lines, string(line.text), err)
+		return
+	}
+	p.stats.observeEntry(entry)
+
+	if p.opts.TLSMetrics {
+		p.aggregator.RecordTLS(entry)
+	}
+
+	rule, matched := p.normalizeEntry(entry)
+	if !matched {
+		return
+	}
+	p.stats.matchedLines++
+	p.aggregator.recordMetrics(entry, rule.name, rule.metrics, rule.targetDimension(now), p.segments(rule, entry))
+	p.aggregator.recordSLOs(entry, p.slos[rule.name])
+	if p.exemplars != nil {
+		p.exemplars.record(entry, rule.name, p.aggregator.failed(entry), line.text)
+	}
+}
+
+// segments returns the breakdowns the rule publishes entry in. The result is only valid until the next call.
+func (p *Processor) segments(rule *compiledRule, entry albLogEntry) []segment {
+	segments := p.segmentBuf[:0]
+
+	if rule.clientType && p.opts.ClientTypes != nil {
+		segments = append(segments, segment{Name: metricDimensionClientType, Value: p.opts.ClientTypes.classify(entry.userAgent)})
+	}
+
+	if p.opts.ClientNetworks != nil {
+		for _, dimension := range rule.networkDimensions {
+			if value, ok := p.opts.ClientNetworks.value(dimension, entry.clientAddr); ok {
+				segments = append(segments, segment{Name: dimension, Value: value})
+			}
 		}
-		return r
 	}
 
+	p.segmentBuf = segments
+	return segments
+}
+
+// isTruncatedStream reports whether err indicates a compressed object that ended early or failed its checksum.
+func isTruncatedStream(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, zstd.ErrCRCMismatch)
+}
+
+// logParseErrors logs the parse failure counts and samples for a single object.
+func logParseErrors(logger *slog.Logger, stats *parseErrorStats) {
+	if stats.total() == 0 {
+		return
+	}
+
+	logger.Warn("parse errors", "parse_errors", stats.total(), "lines", stats.lines, "reasons", stats.errors)
+	for _, sample := range stats.samples {
+		logger.Warn("parse error sample",
+			"line_number", sample.lineNumber,
+			"reason", sample.reason,
+			"error", sample.err,
+			"line", sample.line,
+		)
+	}
+}
+
+// CoverageReport summarizes rule coverage for the lines processed so far, listing at most topN unmatched paths.
+func (p *Processor) CoverageReport(topN int) (CoverageReport, error) {
+	if p.coverage == nil {
+		return CoverageReport{}, fmt.Errorf("coverage tracking is not enabled")
+	}
+
+	return p.coverage.report(p.rules, topN), nil
+}
+
+// logMetrics logs every metric datum as a structured debug record.
+func logMetrics(ctx context.Context, logger *slog.Logger, metricData []types.MetricDatum) {
 	for _, data := range metricData {
-		switch aws.ToString(data.MetricName) {
-		case metricNameTargetResponseTime:
-			fmt.Printf("Metric: %s, Dimensions: %v, Timestamp: %v, Values: %v, Counts: %v\n",
-				aws.ToString(data.MetricName),
-				expandDimensions(data.Dimensions),
-				data.Timestamp,
-				data.Values,
-				data.Counts,
-			)
-		case metricNameRequestCount, metricNameFailedRequestCount:
-			fmt.Printf("Metric: %s, Dimensions: %v, Timestamp: %v, Value: %v\n",
-				aws.ToString(data.MetricName),
-				expandDimensions(data.Dimensions),
-				data.Timestamp,
-				aws.ToFloat64(data.Value),
-			)
+		dimensions := make(map[string]string, len(data.Dimensions))
+		for _, d := range data.Dimensions {
+			dimensions[aws.ToString(d.Name)] = aws.ToString(d.Value)
+		}
+
+		attrs := []slog.Attr{
+			slog.String("metric", aws.ToString(data.MetricName)),
+			slog.Any("dimensions", dimensions),
+			slog.Time("timestamp", aws.ToTime(data.Timestamp)),
+			slog.String("unit", string(data.Unit)),
 		}
+		if data.Value != nil {
+			attrs = append(attrs, slog.Float64("value", aws.ToFloat64(data.Value)))
+		} else {
+			attrs = append(attrs, slog.Any("values", data.Values), slog.Any("counts", data.Counts))
+		}
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "metric datum", attrs...)
 	}
 }
 
@@ -140,12 +454,33 @@ func (p *Processor) normalizeLogLine(line string) (*albLogEntry, string, bool) {
 		return nil, "", false
 	}
 
-	name, matched := p.rules.normalize(*entry)
+	rule, matched := p.normalizeEntry(*entry)
 	if !matched {
 		return nil, "", false
 	}
 
-	return entry, name, true
+	return entry, rule.name, true
+}
+
+// normalizeEntry returns the rule matching a parsed entry and records rule coverage.
+func (p *Processor) normalizeEntry(entry albLogEntry) (*compiledRule, bool) {
+	if p.rules == nil || !p.rules.enabled {
+		return nil, false
+	}
+
+	idx, matched := p.rules.match(entry)
+	if p.coverage != nil {
+		p.coverage.record(entry, idx, matched)
+	}
+
+	if !matched {
+		if p.opts.PublishUnmatchedCount {
+			p.aggregator.RecordUnmatched(entry)
+		}
+		return nil, false
+	}
+
+	return &p.rules.rules[idx], true
 }
 
 // MetricsProcessor exposes the processor type for tests.
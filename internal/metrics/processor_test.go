@@ -1,6 +1,12 @@
 package metrics
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,3 +53,77 @@ func TestNormalizeLogLine_ParseError(t *testing.T) {
 	assert.Equal(t, "", name)
 	assert.False(t, ok)
 }
+
+func TestProcessLines_CountsParseErrors(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"api.example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`)
+	require.NoError(t, err)
+	processor := NewProcwessor(nil, nil, rules, ProcessorOptions{ParseErrorSamples: 1})
+
+	valid := testLogLine("GET", "http://api.example.com/users/1")
+	lines := []string{
+		valid,
+		"invalid",
+		strings.Replace(valid, " 200 200 ", " - 200 ", 1),
+		"",
+		valid,
+	}
+
+	stats, err := processor.processLines(strings.NewReader(strings.Join(lines, "\n")))
+
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.lines)
+	assert.Equal(t, map[string]int{parseErrorMissingFields: 1, parseErrorStatus: 1}, stats.errors)
+	require.Len(t, stats.samples, 1)
+	assert.Equal(t, 2, stats.samples[0].lineNumber)
+	assert.Equal(t, "invalid", stats.samples[0].line)
+	assert.InDelta(t, 0.5, stats.rate(), 1e-9)
+}
+
+func TestProcessLines_TruncatedGzip(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"api.example.com","pattern":"^/users/[0-9]+$","name":"/users/:id"}]`)
+	require.NoError(t, err)
+	processor := NewProcwessor(nil, nil, rules, ProcessorOptions{})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for range 100 {
+		_, err := gz.Write([]byte(testLogLine("GET", "http://api.example.com/users/1") + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gz.Close())
+
+	truncated := buf.Bytes()[:buf.Len()-8]
+	gzipReader, err := gzip.NewReader(bytes.NewReader(truncated))
+	require.NoError(t, err)
+
+	stats, err := processor.processLines(gzipReader)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.errors[parseErrorTruncatedObject])
+}
+
+func TestLogMetrics_StructuredRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	aggregator := &MetricAggregator{metrics: make(map[metricKey]*metricAggregate)}
+	aggregator.Record(albLogEntry{method: "GET", host: "api.example.com", status: 200, targetProcessingTime: 0.5, timestamp: parseTime(t, "2024-01-15T10:00:00Z")}, "/users/:id")
+
+	logMetrics(context.Background(), logger, aggregator.GetCloudWatchMetricData())
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]any
+		require.NoError(t, dec.Decode(&record))
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 3)
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, metricNameTargetResponseTime, records[0]["metric"])
+	assert.Equal(t, map[string]any{"Method": "GET", "Host": "api.example.com", "Path": "/users/:id"}, records[0]["dimensions"])
+	assert.Equal(t, []any{0.5}, records[0]["values"])
+	assert.Equal(t, metricNameRequestCount, records[1]["metric"])
+	assert.Equal(t, 1.0, records[1]["value"])
+}
@@ -3,6 +3,7 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
@@ -11,16 +12,26 @@ import (
 
 const defaultMetricBatchSize = 20
 
+// MetricPutter is the subset of the CloudWatch API used to publish metric data.
+type MetricPutter interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
 // cloudWatchMetricPublisher sends metric data to CloudWatch using PutMetricData.
 type cloudWatchMetricPublisher struct {
-	client       *cloudwatch.Client
+	client       MetricPutter
 	namespace    string
 	maxBatchSize int
 	dryRun       bool
+
+	// Counters reported as pipeline metrics.
+	datumsPublished int
+	putCalls        int
+	putFailures     int
 }
 
 // Publish sends metric data to CloudWatch in batches that respect PutMetricData limits.
-func (p *cloudWatchMetricPublisher) publish(ctx context.Context, data []types.MetricDatum) error {
+func (p *cloudWatchMetricPublisher) publish(ctx context.Context, logger *slog.Logger, data []types.MetricDatum) error {
 	if len(data) == 0 {
 		return nil
 	}
@@ -30,10 +41,11 @@ func (p *cloudWatchMetricPublisher) publish(ctx context.Context, data []types.Me
 		return fmt.Errorf("prepare metric batches: %w", err)
 	}
 
-	fmt.Printf("Publishing %d metrics in %d batches to CloudWatch namespace %q\n", len(data), len(chunks), p.namespace)
+	logger = logger.With("namespace", p.namespace)
+	logger.Info("publishing metrics", "datums", len(data), "batches", len(chunks))
 
 	if p.dryRun {
-		fmt.Println("Dry run enabled, skipping actual publishing")
+		logger.Info("dry run enabled, skipping actual publishing")
 		return nil
 	}
 
@@ -47,9 +59,12 @@ func (p *cloudWatchMetricPublisher) publish(ctx context.Context, data []types.Me
 			MetricData: chunk,
 		}
 
+		p.putCalls++
 		if _, err := p.client.PutMetricData(ctx, input); err != nil {
+			p.putFailures++
 			return fmt.Errorf("put metric data: %w", err)
 		}
+		p.datumsPublished += len(chunk)
 	}
 
 	return nil
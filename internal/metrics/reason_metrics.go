@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Metrics that count requests per value of an ALB field explaining how ALB handled them.
+const (
+	metricNameErrorReasonCount          = "ErrorReasonCount"
+	metricNameActionsExecutedCount      = "ActionsExecutedCount"
+	metricNameClassificationCount       = "ClassificationCount"
+	metricNameClassificationReasonCount = "ClassificationReasonCount"
+
+	metricDimensionAction         = "Action"
+	metricDimensionClassification = "Classification"
+
+	// otherFieldValue is published for values outside the allowlist, so that unexpected values cannot
+	// grow the number of metrics.
+	otherFieldValue = "Other"
+
+	// maxMetricSpecValues bounds the allowlist a metric spec can configure.
+	maxMetricSpecValues = 50
+)
+
+// Default allowlists, taken from the values documented for ALB access logs.
+var (
+	errorReasons = []string{
+		"AuthInvalidCookie", "AuthInvalidGrantError", "AuthInvalidIdToken", "AuthInvalidStateParam",
+		"AuthInvalidTokenResponse", "AuthInvalidUserinfoResponse", "AuthMissingCodeParam", "AuthMissingHostHeader",
+		"AuthMissingStateParam", "AuthTokenEpRequestFailed", "AuthTokenEpRequestTimeout", "AuthUnhandledException",
+		"AuthUserinfoEpRequestFailed", "AuthUserinfoEpRequestTimeout", "AuthUserinfoResponseSizeExceeded",
+		"LambdaAccessDenied", "LambdaBadRequest", "LambdaConnectionError", "LambdaConnectionTimeout",
+		"LambdaEC2AccessDeniedException", "LambdaEC2ThrottledException", "LambdaEC2UnexpectedException",
+		"LambdaENILimitReachedException", "LambdaInvalidResponse", "LambdaInvalidRuntimeException",
+		"LambdaInvalidSecurityGroupIDException", "LambdaInvalidSubnetIDException", "LambdaInvalidZipFileException",
+		"LambdaKMSAccessDeniedException", "LambdaKMSDisabledException", "LambdaKMSInvalidStateException",
+		"LambdaKMSNotFoundException", "LambdaRequestTooLarge", "LambdaResourceNotFound", "LambdaResponseTooLarge",
+		"LambdaServiceException", "LambdaSubnetIPAddressLimitReachedException", "LambdaThrottling",
+		"LambdaUnhandled", "OutpostTargetConnectionError", "TargetConnectionError", "TargetResponseError",
+		"TargetTimeout", "WAFConnectionError", "WAFConnectionTimeout", "WAFResponseReadTimeout",
+		"WAFServiceError", "WAFUnhandledException",
+	}
+	actions = []string{
+		"authenticate", "fixed-response", "forward", "lambda", "redirect", "rewrite", "waf", "waf-failed",
+	}
+	classifications = []string{
+		"Acceptable", "Ambiguous", "Severe",
+	}
+	classificationReasons = []string{
+		"AmbiguousUri", "BadContentLength", "BadHeader", "BadTransferEncoding", "BadUri", "BadMethod",
+		"BadVersion", "BothTeClPresent", "DuplicateContentLength", "EmptyHeader", "GetHeadZeroContentLength",
+		"MultipleContentLength", "MultipleTransferEncodingChunked", "NonCompliantHeader", "NonCompliantVersion",
+		"SpaceInUri", "SuspiciousHeader", "SuspiciousTeClPresent", "UndefinedContentLengthSemantics",
+		"UndefinedTransferEncodingSemantics",
+	}
+)
+
+// countFieldValue increments the count of an ALB field value, ignoring the "-" ALB logs for none.
+func countFieldValue(counts *map[string]int, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+
+	if *counts == nil {
+		*counts = make(map[string]int)
+	}
+	(*counts)[value]++
+}
+
+// appendLabeledMetricData appends one datum per allowlisted value with a non-zero count, folding
+// every other value into Other.
+func appendLabeledMetricData(metricData []types.MetricDatum, spec metricSpec, def metricDefinition, agg *metricAggregate, dimensions []types.Dimension, timestamp time.Time) []types.MetricDatum {
+	allowlist := def.allowlist
+	if len(spec.Values) > 0 {
+		allowlist = spec.Values
+	}
+
+	counts := make(map[string]int)
+	for value, n := range def.labels(agg) {
+		if !slices.Contains(allowlist, value) {
+			value = otherFieldValue
+		}
+		counts[value] += n
+	}
+
+	for _, value := range slices.Sorted(maps.Keys(counts)) {
+		metricData = append(metricData, types.MetricDatum{
+			MetricName: aws.String(spec.name()),
+			Timestamp:  aws.Time(timestamp),
+			Dimensions: append(slices.Clip(dimensions), types.Dimension{Name: aws.String(def.dimension), Value: aws.String(value)}),
+			Value:      aws.Float64(float64(counts[value])),
+			Unit:       types.StandardUnitCount,
+		})
+	}
+	return metricData
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLines_ReasonMetrics(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"example.com","pattern":"^/login$","name":"/login","metrics":[
+		"ErrorReasonCount",
+		"ActionsExecutedCount",
+		{"metric":"ClassificationCount","values":["Severe"]},
+		"ClassificationReasonCount"
+	]}]`)
+	require.NoError(t, err)
+
+	ok := testFields("POST", "example.com", "/login")
+	ok.ActionsExecuted = "waf,forward"
+
+	refused := testFields("POST", "example.com", "/login")
+	refused.ELBStatusCode = 502
+	refused.ErrorReason = "TargetConnectionError"
+
+	unknown := testFields("POST", "example.com", "/login")
+	unknown.ErrorReason = "SomethingNew"
+	unknown.ActionsExecuted = "authenticate"
+	unknown.Classification = "Ambiguous"
+	unknown.ClassificationReason = "SpaceInUri"
+
+	lines := []string{ok.String(), refused.String(), unknown.String()}
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true})
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	got := make(map[string]float64)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		require.Len(t, datum.Dimensions, 4)
+		dimension := datum.Dimensions[3]
+		got[aws.ToString(datum.MetricName)+" "+aws.ToString(dimension.Name)+"="+aws.ToString(dimension.Value)] = aws.ToFloat64(datum.Value)
+	}
+
+	assert.Equal(t, map[string]float64{
+		"ErrorReasonCount Reason=Other":                 1,
+		"ErrorReasonCount Reason=TargetConnectionError": 1,
+		"ActionsExecutedCount Action=authenticate":      1,
+		"ActionsExecutedCount Action=forward":           2,
+		"ActionsExecutedCount Action=waf":               1,
+		"ClassificationCount Classification=Other":      1,
+		"ClassificationReasonCount Reason=SpaceInUri":   1,
+	}, got)
+}
+
+func TestParseMetricSpecs_Values(t *testing.T) {
+	_, err := ParseMetricSpecs(`[{"metric":"RequestCount","values":["a"]}]`)
+	assert.ErrorContains(t, err, "RequestCount does not take values")
+
+	values := `"` + strings.Repeat(`v","`, maxMetricSpecValues) + `v"`
+	_, err = ParseMetricSpecs(`[{"metric":"ErrorReasonCount","values":[` + values + `]}]`)
+	assert.ErrorContains(t, err, "at most 50 values are allowed")
+}
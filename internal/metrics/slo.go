@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Names of the SLO metrics and their dimension, for tools that build alarms and dashboards on them.
+const (
+	MetricNameSLOGoodCount  = "SLOGoodCount"
+	MetricNameSLOTotalCount = "SLOTotalCount"
+
+	MetricDimensionSLO = "SLO"
+
+	// defaultSLOWindowDays is the error budget window of SLOs that do not set window_days.
+	defaultSLOWindowDays = 30
+)
+
+// slo is a service level objective on the requests of a rule name.
+type slo struct {
+	// Name identifies the SLO in the SLO dimension.
+	Name string `json:"name"`
+	// Path is the rule name the SLO covers, and Host optionally narrows it to one host.
+	Path string `json:"path"`
+	Host string `json:"host,omitempty"`
+	// Objective is the percentage of good requests, such as 99.9.
+	Objective float64 `json:"objective"`
+	// Latency makes the SLO a latency objective: good requests also respond within it, such as "300ms".
+	// Without it, every request that did not fail is good.
+	Latency string `json:"latency,omitempty"`
+	// WindowDays is the period the error budget is spent over. Defaults to 30.
+	WindowDays int `json:"window_days,omitempty"`
+
+	latency time.Duration
+}
+
+// ParseSLOs parses a JSON list of SLOs. An empty string returns nil.
+func ParseSLOs(raw string) ([]SLO, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var slos []slo
+	if err := json.Unmarshal([]byte(trimmed), &slos); err != nil {
+		return nil, fmt.Errorf("failed to parse SLOs JSON: %w", err)
+	}
+	if len(slos) == 0 {
+		return nil, fmt.Errorf("SLOs must not be empty")
+	}
+
+	names := make(map[string]bool, len(slos))
+	for idx := range slos {
+		s := &slos[idx]
+		if s.Name == "" {
+			return nil, fmt.Errorf("SLO %d: name is required", idx)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("SLO %d: name %q is used more than once", idx, s.Name)
+		}
+		names[s.Name] = true
+
+		if s.Path == "" {
+			return nil, fmt.Errorf("SLO %d: path is required", idx)
+		}
+
+		if s.Objective <= 0 || s.Objective >= 100 {
+			return nil, fmt.Errorf("SLO %d: objective must be between 0 and 100, got %v", idx, s.Objective)
+		}
+
+		if s.Latency != "" {
+			latency, err := time.ParseDuration(s.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("SLO %d: failed to parse latency: %w", idx, err)
+			}
+			if latency <= 0 {
+				return nil, fmt.Errorf("SLO %d: latency must be positive", idx)
+			}
+			s.latency = latency
+		}
+
+		if s.WindowDays < 0 {
+			return nil, fmt.Errorf("SLO %d: window_days must be positive", idx)
+		}
+		if s.WindowDays == 0 {
+			s.WindowDays = defaultSLOWindowDays
+		}
+	}
+
+	return slos, nil
+}
+
+// ErrorBudget returns the fraction of requests allowed to be bad.
+func (s *slo) ErrorBudget() float64 {
+	return 1 - s.Objective/100
+}
+
+// good reports whether a request counts towards the objective, and whether it counts at all: latency
+// objectives leave out requests that were neither failed nor answered by a target, such as redirects.
+func (s *slo) good(entry albLogEntry, failed bool) (good, counted bool) {
+	if failed {
+		return false, true
+	}
+	if s.latency == 0 {
+		return true, true
+	}
+	if entry.targetProcessingTime < 0 {
+		return false, false
+	}
+	return entry.targetProcessingTime <= s.latency.Seconds(), true
+}
+
+type sloKey struct {
+	Name   string
+	Minute time.Time
+}
+
+type sloAggregate struct {
+	slo   *slo
+	good  int
+	total int
+}
+
+// recordSLOs counts the entry against the SLOs of its rule name.
+func (m *metricAggregator) recordSLOs(entry albLogEntry, slos []*slo) {
+	if len(slos) == 0 {
+		return
+	}
+
+	failed := m.failed(entry)
+	minute := entry.timestamp.UTC().Truncate(time.Minute)
+	for _, s := range slos {
+		if s.Host != "" && s.Host != entry.host {
+			continue
+		}
+
+		good, counted := s.good(entry, failed)
+		if !counted {
+			continue
+		}
+
+		if m.slos == nil {
+			m.slos = make(map[sloKey]*sloAggregate)
+		}
+		key := sloKey{Name: s.Name, Minute: minute}
+		agg, ok := m.slos[key]
+		if !ok {
+			agg = &sloAggregate{slo: s}
+			m.slos[key] = agg
+		}
+
+		agg.total++
+		if good {
+			agg.good++
+		}
+	}
+}
+
+// appendSLOMetricData appends the good and total counts of each SLO and minute. Ratios such as the burn
+// rate are left to metric math over the counts: every log file is processed by its own invocation, so
+// ratios published per invocation could not be combined.
+func (m *metricAggregator) appendSLOMetricData(metricData []types.MetricDatum) []types.MetricDatum {
+	keys := slices.SortedFunc(maps.Keys(m.slos), func(a, b sloKey) int {
+		return cmp.Or(a.Minute.Compare(b.Minute), strings.Compare(a.Name, b.Name))
+	})
+
+	for _, key := range keys {
+		agg := m.slos[key]
+		dimensions := []types.Dimension{
+			{Name: aws.String(MetricDimensionSLO), Value: aws.String(key.Name)},
+		}
+
+		for _, datum := range []struct {
+			name  string
+			value int
+		}{
+			{MetricNameSLOGoodCount, agg.good},
+			{MetricNameSLOTotalCount, agg.total},
+		} {
+			metricData = append(metricData, types.MetricDatum{
+				MetricName: aws.String(datum.name),
+				Timestamp:  aws.Time(key.Minute),
+				Dimensions: dimensions,
+				Value:      aws.Float64(float64(datum.value)),
+				Unit:       types.StandardUnitCount,
+			})
+		}
+	}
+	return metricData
+}
+
+// slosByPath groups SLOs by the rule name they cover.
+func slosByPath(slos []slo) map[string][]*slo {
+	byPath := make(map[string][]*slo)
+	for idx := range slos {
+		byPath[slos[idx].Path] = append(byPath[slos[idx].Path], &slos[idx])
+	}
+	return byPath
+}
+
+// SLO exposes the SLO configuration type for configuration and generators.
+type SLO = slo
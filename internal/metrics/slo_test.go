@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSLOs(t *testing.T) {
+	slos, err := ParseSLOs(`[
+		{"name":"users-availability","path":"/users/:id","objective":99.9},
+		{"name":"checkout-latency","path":"/checkout","host":"shop.example.com","objective":99,"latency":"300ms","window_days":28}
+	]`)
+	require.NoError(t, err)
+	require.Len(t, slos, 2)
+
+	assert.Equal(t, defaultSLOWindowDays, slos[0].WindowDays)
+	assert.Zero(t, slos[0].latency)
+	assert.Equal(t, 28, slos[1].WindowDays)
+	assert.Equal(t, 300*time.Millisecond, slos[1].latency)
+
+	slos, err = ParseSLOs("")
+	require.NoError(t, err)
+	assert.Nil(t, slos)
+}
+
+func TestParseSLOs_Errors(t *testing.T) {
+	tests := map[string]struct {
+		raw  string
+		want string
+	}{
+		"invalid JSON":     {raw: `[`, want: "failed to parse SLOs JSON"},
+		"empty":            {raw: `[]`, want: "SLOs must not be empty"},
+		"missing name":     {raw: `[{"path":"/","objective":99}]`, want: "SLO 0: name is required"},
+		"missing path":     {raw: `[{"name":"a","objective":99}]`, want: "SLO 0: path is required"},
+		"objective of 100": {raw: `[{"name":"a","path":"/","objective":100}]`, want: "objective must be between 0 and 100"},
+		"invalid latency":  {raw: `[{"name":"a","path":"/","objective":99,"latency":"fast"}]`, want: "failed to parse latency"},
+		"negative window":  {raw: `[{"name":"a","path":"/","objective":99,"window_days":-1}]`, want: "window_days must be positive"},
+		"duplicate name": {
+			raw:  `[{"name":"a","path":"/","objective":99},{"name":"a","path":"/x","objective":99}]`,
+			want: `SLO 1: name "a" is used more than once`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSLOs(tt.raw)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestProcessLines_SLOs(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"example.com","pattern":"^/checkout$","name":"/checkout"}]`)
+	require.NoError(t, err)
+
+	slos, err := ParseSLOs(`[
+		{"name":"availability","path":"/checkout","objective":99},
+		{"name":"latency","path":"/checkout","objective":90,"latency":"300ms"},
+		{"name":"other-host","path":"/checkout","host":"shop.example.com","objective":99}
+	]`)
+	require.NoError(t, err)
+
+	var lines []string
+	for range 7 {
+		fields := testFields("POST", "example.com", "/checkout")
+		fields.TargetProcessingTime = 0.1
+		lines = append(lines, fields.String())
+	}
+
+	slow := testFields("POST", "example.com", "/checkout")
+	slow.TargetProcessingTime = 0.5
+	failed := testFields("POST", "example.com", "/checkout")
+	failed.ELBStatusCode = 503
+	redirect := testFields("POST", "example.com", "/checkout")
+	redirect.ELBStatusCode = 301
+	redirect.TargetProcessingTime = -1
+	redirect.ActionsExecuted = "redirect"
+	lines = append(lines, slow.String(), failed.String(), redirect.String())
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, SLOs: slos})
+	require.NoError(t, p.ProcessLines(strings.NewReader(strings.Join(lines, "\n"))))
+
+	got := make(map[string]float64)
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		if len(datum.Dimensions) != 1 || aws.ToString(datum.Dimensions[0].Name) != MetricDimensionSLO {
+			continue
+		}
+		got[aws.ToString(datum.Dimensions[0].Value)+" "+aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+
+	assert.Equal(t, map[string]float64{
+		// 1 of 10 requests failed.
+		"availability SLOGoodCount":  9,
+		"availability SLOTotalCount": 10,
+		// The redirect is left out, and 2 of 9 requests are bad.
+		"latency SLOGoodCount":  7,
+		"latency SLOTotalCount": 9,
+	}, got)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	metricNameTLSRequestCount = "TLSRequestCount"
+
+	metricDimensionTLSProtocol = "TLSProtocol"
+	metricDimensionCipher      = "Cipher"
+
+	// tlsProtocol13 is the ssl_protocol value skipped when only legacy TLS traffic is counted.
+	tlsProtocol13 = "TLSv1.3"
+)
+
+// tlsKey identifies the requests of a host negotiated with one protocol and cipher in a minute.
+type tlsKey struct {
+	Host     string
+	Minute   time.Time
+	Protocol string
+	Cipher   string
+}
+
+// RecordTLS counts a request by its negotiated TLS protocol and cipher. Plain HTTP requests are ignored,
+// and so are TLS 1.3 requests when only legacy traffic is counted.
+func (m *metricAggregator) RecordTLS(entry albLogEntry) {
+	if entry.sslProtocol == "" || entry.sslProtocol == "-" {
+		return
+	}
+	if m.tlsLegacyOnly && entry.sslProtocol == tlsProtocol13 {
+		return
+	}
+
+	if m.tls == nil {
+		m.tls = make(map[tlsKey]int)
+	}
+
+	minute := entry.timestamp.UTC().Truncate(time.Minute)
+	m.tls[tlsKey{Host: entry.host, Minute: minute, Protocol: entry.sslProtocol, Cipher: entry.sslCipher}]++
+}
+
+// appendTLSMetricData appends a TLSRequestCount datum per host, protocol and cipher.
+func (m *metricAggregator) appendTLSMetricData(metricData []types.MetricDatum) []types.MetricDatum {
+	keys := slices.SortedFunc(maps.Keys(m.tls), func(a, b tlsKey) int {
+		return cmp.Or(
+			a.Minute.Compare(b.Minute),
+			strings.Compare(a.Host, b.Host),
+			strings.Compare(a.Protocol, b.Protocol),
+			strings.Compare(a.Cipher, b.Cipher),
+		)
+	})
+
+	for _, key := range keys {
+		metricData = append(metricData, types.MetricDatum{
+			MetricName: aws.String(metricNameTLSRequestCount),
+			Timestamp:  aws.Time(key.Minute),
+			Dimensions: []types.Dimension{
+				{Name: aws.String(metricDimensionHost), Value: aws.String(key.Host)},
+				{Name: aws.String(metricDimensionTLSProtocol), Value: aws.String(key.Protocol)},
+				{Name: aws.String(metricDimensionCipher), Value: aws.String(key.Cipher)},
+			},
+			Value: aws.Float64(float64(m.tls[key])),
+			Unit:  types.StandardUnitCount,
+		})
+	}
+	return metricData
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessLines_TLSMetrics(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"example.com","pattern":"^/users$","name":"/users"}]`)
+	require.NoError(t, err)
+
+	tls12 := testFields("GET", "example.com", "/users")
+
+	tls13 := testFields("GET", "example.com", "/users")
+	tls13.SSLCipher, tls13.SSLProtocol = "TLS_AES_128_GCM_SHA256", "TLSv1.3"
+
+	tls10 := testFields("GET", "legacy.example.com", "/unmatched")
+	tls10.SSLCipher, tls10.SSLProtocol = "ECDHE-RSA-AES128-SHA", "TLSv1"
+
+	plain := testFields("GET", "example.com", "/users")
+	plain.Type = "http"
+	plain.SSLCipher, plain.SSLProtocol = "-", "-"
+
+	lines := strings.Join([]string{tls12.String(), tls12.String(), tls13.String(), tls10.String(), plain.String()}, "\n")
+
+	tests := map[string]struct {
+		legacyOnly bool
+		want       map[string]float64
+	}{
+		"all": {
+			want: map[string]float64{
+				"example.com TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256": 2,
+				"example.com TLSv1.3 TLS_AES_128_GCM_SHA256":      1,
+				"legacy.example.com TLSv1 ECDHE-RSA-AES128-SHA":   1,
+			},
+		},
+		"legacy only": {
+			legacyOnly: true,
+			want: map[string]float64{
+				"example.com TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256": 2,
+				"legacy.example.com TLSv1 ECDHE-RSA-AES128-SHA":   1,
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true, TLSMetrics: true, TLSLegacyOnly: tt.legacyOnly})
+			require.NoError(t, p.ProcessLines(strings.NewReader(lines)))
+
+			got := make(map[string]float64)
+			for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+				if aws.ToString(datum.MetricName) != metricNameTLSRequestCount {
+					continue
+				}
+				require.Len(t, datum.Dimensions, 3)
+				key := aws.ToString(datum.Dimensions[0].Value) + " " + aws.ToString(datum.Dimensions[1].Value) + " " + aws.ToString(datum.Dimensions[2].Value)
+				got[key] = aws.ToFloat64(datum.Value)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProcessLines_TLSMetricsDisabled(t *testing.T) {
+	rules, err := NewPathRules(`[{"host":"example.com","pattern":"^/users$","name":"/users"}]`)
+	require.NoError(t, err)
+
+	p := NewProcwessor(nil, nil, rules, ProcessorOptions{DryRun: true})
+	require.NoError(t, p.ProcessLines(strings.NewReader(testFields("GET", "example.com", "/users").String())))
+
+	for _, datum := range p.aggregator.GetCloudWatchMetricData() {
+		assert.NotEqual(t, metricNameTLSRequestCount, aws.ToString(datum.MetricName))
+	}
+}
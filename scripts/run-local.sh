@@ -1,16 +1,46 @@
 #!/usr/bin/env bash
 
 # container build . -t $(basename $(pwd))
-credentials=$(aws configure export-credentials)
+# With LOCAL_AWS_ENDPOINT set (e.g. http://host.docker.internal:4566), S3 and CloudWatch
+# requests go to cmd/local-aws instead of AWS and no credentials are needed.
+if [ -n "$LOCAL_AWS_ENDPOINT" ]; then
+  endpoint_args=(-e AWS_ENDPOINT_URL="$LOCAL_AWS_ENDPOINT" -e S3_USE_PATH_STYLE=true)
+  access_key_id=test
+  secret_access_key=test
+  session_token=
+else
+  credentials=$(aws configure export-credentials)
+  endpoint_args=()
+  access_key_id=$(echo "$credentials" | jq -r '.AccessKeyId')
+  secret_access_key=$(echo "$credentials" | jq -r '.SecretAccessKey')
+  session_token=$(echo "$credentials" | jq -r '.SessionToken')
+fi
+
 container run \
   --rm \
   -e AWS_REGION=ap-northeast-1 \
-  -e AWS_ACCESS_KEY_ID=$(echo "$credentials" | jq -r '.AccessKeyId') \
-  -e AWS_SECRET_ACCESS_KEY=$(echo "$credentials" | jq -r '.SecretAccessKey') \
-  -e AWS_SESSION_TOKEN=$(echo "$credentials" | jq -r '.SessionToken') \
+  -e AWS_ACCESS_KEY_ID=$access_key_id \
+  -e AWS_SECRET_ACCESS_KEY=$secret_access_key \
+  -e AWS_SESSION_TOKEN=$session_token \
+  "${endpoint_args[@]}" \
   -e INCLUDE_PATH_RULES \
   -e DRY_RUN \
-  -e DEBUG \
+  -e LOG_LEVEL \
+  -e COVERAGE_REPORT \
+  -e PUBLISH_UNMATCHED_COUNT \
+  -e PARSE_ERROR_THRESHOLD \
+  -e PARSE_ERROR_SAMPLES \
+  -e PIPELINE_METRICS_NAMESPACE \
+  -e LOG_KEY_PREFIX \
+  -e MAX_LINE_BYTES \
+  -e PATH_METRICS \
+  -e UNAVAILABLE_AS_FAILED \
+  -e MAX_TARGETS \
+  -e TLS_METRICS \
+  -e TLS_LEGACY_ONLY \
+  -e CLIENT_TYPES \
+  -e CLIENT_NETWORKS \
+  -e EXEMPLARS \
+  -e SLOS \
   -p 9000:8080 \
   cloudwatch-alb-path-metrics
-